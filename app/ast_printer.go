@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"math/big"
 	"strings"
 )
 
@@ -43,6 +44,18 @@ func (a *AstPrinter) printStmt(stmt Stmt) string {
         return a.visitReturnStmt(s).(string)
     case *WhileStatement:
         return a.visitWhileStmt(s).(string)
+    case *ForInStatement:
+        return a.visitForInStmt(s).(string)
+    case *YieldStatement:
+        return a.visitYieldStmt(s).(string)
+    case *SelectStatement:
+        return a.visitSelectStmt(s).(string)
+    case *EnumStatement:
+        return a.visitEnumStmt(s).(string)
+    case *MatchStatement:
+        return a.visitMatchStmt(s).(string)
+    case *DestructureStatement:
+        return a.visitDestructureStmt(s).(string)
     default:
         return fmt.Sprintf("(unknown %T)", stmt)
     }
@@ -64,6 +77,9 @@ func (a *AstPrinter) visitBlockStmt(stmt *Block) interface{} {
 
 // Ensure all visit methods return strings
 func (a *AstPrinter) visitVarStmt(stmt *VarStatement) interface{} {
+    if stmt.IsConst {
+        return fmt.Sprintf("(const %s %s)", stmt.Name.Lexeme, a.printExpr(stmt.Initializer))
+    }
     if stmt.Initializer == nil {
         return fmt.Sprintf("(var %s)", stmt.Name.Lexeme)
     }
@@ -72,7 +88,11 @@ func (a *AstPrinter) visitVarStmt(stmt *VarStatement) interface{} {
 
 func (a *AstPrinter) visitFunctionStmt(stmt *FunctionStatement) interface{} {
     var sb strings.Builder
-    sb.WriteString("(fun ")
+    if stmt.IsAsync {
+        sb.WriteString("(async fun ")
+    } else {
+        sb.WriteString("(fun ")
+    }
     sb.WriteString(stmt.Name.Lexeme)
     sb.WriteString(" (")
     
@@ -81,6 +101,17 @@ func (a *AstPrinter) visitFunctionStmt(stmt *FunctionStatement) interface{} {
             sb.WriteString(" ")
         }
         sb.WriteString(param.Lexeme)
+        if stmt.Defaults[i] != nil {
+            sb.WriteString("=")
+            sb.WriteString(a.printExpr(stmt.Defaults[i]))
+        }
+    }
+    if stmt.Rest != nil {
+        if len(stmt.Params) > 0 {
+            sb.WriteString(" ")
+        }
+        sb.WriteString("...")
+        sb.WriteString(stmt.Rest.Lexeme)
     }
     sb.WriteString(")")
     
@@ -129,6 +160,116 @@ func (a *AstPrinter) visitWhileStmt(stmt *WhileStatement) interface{} {
 		a.printStmt(stmt.Body))
 }
 
+func (a *AstPrinter) visitForInStmt(stmt *ForInStatement) interface{} {
+	return fmt.Sprintf("(for-in %s %s %s)",
+		stmt.Name.Lexeme,
+		a.printExpr(stmt.Iterable),
+		a.printStmt(stmt.Body))
+}
+
+func (a *AstPrinter) visitYieldStmt(stmt *YieldStatement) interface{} {
+	if stmt.Value == nil {
+		return "(yield)"
+	}
+	return a.parenthesize("yield", stmt.Value)
+}
+
+func (a *AstPrinter) visitSelectStmt(stmt *SelectStatement) interface{} {
+	var sb strings.Builder
+	sb.WriteString("(select")
+	for _, selectCase := range stmt.Cases {
+		sb.WriteString(" (")
+		sb.WriteString(selectCase.Kind.Lexeme)
+		if selectCase.Name != nil {
+			sb.WriteString(" " + selectCase.Name.Lexeme)
+		}
+		if selectCase.Value != nil {
+			sb.WriteString(" " + a.printExpr(selectCase.Value))
+		}
+		if selectCase.Channel != nil {
+			sb.WriteString(" " + a.printExpr(selectCase.Channel))
+		}
+		sb.WriteString(" " + a.printStmt(&Block{Statements: selectCase.Body}) + ")")
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+func (a *AstPrinter) visitEnumStmt(stmt *EnumStatement) interface{} {
+	var sb strings.Builder
+	sb.WriteString("(enum " + stmt.Name.Lexeme)
+	for _, enumCase := range stmt.Cases {
+		sb.WriteString(" " + enumCase.Lexeme)
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+func (a *AstPrinter) visitDestructureStmt(stmt *DestructureStatement) interface{} {
+	return fmt.Sprintf("(var %s %s)", a.printPattern(stmt.Pattern), a.printExpr(stmt.Initializer))
+}
+
+func (a *AstPrinter) visitMatchStmt(stmt *MatchStatement) interface{} {
+	return a.printMatch(stmt.Subject, stmt.Arms)
+}
+
+// printMatch prints a match as `(match subject (=> pattern [(if guard)] body) ...)`
+func (a *AstPrinter) printMatch(subject Expr, arms []*MatchArm) string {
+	var sb strings.Builder
+	sb.WriteString("(match " + a.printExpr(subject))
+	for _, arm := range arms {
+		sb.WriteString(" (=> " + a.printPattern(arm.Pattern))
+		if arm.Guard != nil {
+			sb.WriteString(" (if " + a.printExpr(arm.Guard) + ")")
+		}
+		if arm.Body != nil {
+			sb.WriteString(" " + a.printStmt(arm.Body) + ")")
+		} else {
+			sb.WriteString(" " + a.printExpr(arm.Value) + ")")
+		}
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+func (a *AstPrinter) printPattern(pattern Pattern) string {
+	switch p := pattern.(type) {
+	case *LiteralPattern:
+		return a.printExpr(&LiteralExpr{Value: p.Value})
+	case *WildcardPattern:
+		return "_"
+	case *ValuePattern:
+		return a.printExpr(p.Value)
+	case *BindingPattern:
+		return p.Name.Lexeme
+	case *ListPattern:
+		elements := make([]string, 0, len(p.Elements)+1)
+		for _, element := range p.Elements {
+			elements = append(elements, a.printPattern(element))
+		}
+		if p.Rest != nil {
+			elements = append(elements, "..."+p.Rest.Lexeme)
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *MapPattern:
+		entries := make([]string, 0, len(p.Keys))
+		for index, key := range p.Keys {
+			entries = append(entries, a.printPattern(key)+": "+a.printPattern(p.Values[index]))
+		}
+		return "{" + strings.Join(entries, ", ") + "}"
+	case *ClassPattern:
+		fields := make([]string, 0, len(p.Positional)+len(p.Named))
+		for _, positional := range p.Positional {
+			fields = append(fields, a.printPattern(positional))
+		}
+		for index, name := range p.Names {
+			fields = append(fields, name.Lexeme+": "+a.printPattern(p.Named[index]))
+		}
+		return a.printExpr(p.Class) + "(" + strings.Join(fields, ", ") + ")"
+	}
+	return fmt.Sprintf("(unknown %T)", pattern)
+}
+
 // Expression visitors
 func (a *AstPrinter) visitBinaryExpr(expr *BinaryExpr) interface{} {
 	return a.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right)
@@ -143,6 +284,9 @@ func (a *AstPrinter) visitLiteralExpr(expr *LiteralExpr) interface{} {
         return "nil"
     }
     switch v := expr.Value.(type) {
+    case int64, *big.Int:
+        // Integers print like whole floats to keep the classic Lox output
+        return fmt.Sprintf("%v.0", v)
     case float64:
         if v == float64(int(v)) {
             return fmt.Sprintf("%.1f", v)
@@ -186,13 +330,25 @@ func (a *AstPrinter) visitCallExpr(expr *CallExpression) interface{} {
 		}
 		sb.WriteString(a.printExpr(arg))
 	}
+	for i, arg := range expr.NamedArguments {
+		if i > 0 || len(expr.Arguments) > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(expr.Names[i].Lexeme)
+		sb.WriteString(": ")
+		sb.WriteString(a.printExpr(arg))
+	}
 	sb.WriteString(")")
 	return sb.String()
 }
 
 //Actual implementation not needed unless printing of the expressions is required. The function declarations are needed in order fot the AstPrinter to be an ExprVisitor
 func (a *AstPrinter) visitGetExpr(expr *GetExpression) interface{} {
-    return nil
+	operator := "."
+	if expr.Optional {
+		operator = "?."
+	}
+	return fmt.Sprintf("(%s %s %s)", operator, a.printExpr(expr.Object), expr.Name.Lexeme)
 }
 
 func (a *AstPrinter) visitSetExpr(expr *SetExpression) interface{} {
@@ -207,6 +363,57 @@ func (a *AstPrinter) visitSuperExpr(expr *SuperExpr) interface{} {
     return nil 
 }
 
+func (a *AstPrinter) visitInterpolationExpr(expr *InterpolationExpr) interface{} {
+	return a.parenthesize("interpolate", expr.Parts...)
+}
+
+func (a *AstPrinter) visitCompoundAssignmentExpr(expr *CompoundAssignmentExpr) interface{} {
+	if expr.Postfix {
+		return fmt.Sprintf("(postfix%s %s)", expr.Operator.Lexeme, a.printExpr(expr.Target))
+	}
+	return a.parenthesize(expr.Operator.Lexeme, expr.Target, expr.Value)
+}
+
+func (a *AstPrinter) visitConditionalExpr(expr *ConditionalExpr) interface{} {
+	return a.parenthesize("?:", expr.Condition, expr.ThenBranch, expr.ElseBranch)
+}
+
+func (a *AstPrinter) visitIndexExpr(expr *IndexExpr) interface{} {
+	return a.parenthesize("[]", expr.Object, expr.Index)
+}
+
+func (a *AstPrinter) visitIndexSetExpr(expr *IndexSetExpr) interface{} {
+	return a.parenthesize("[]=", expr.Object, expr.Index, expr.Value)
+}
+
+func (a *AstPrinter) visitListExpr(expr *ListExpr) interface{} {
+	return a.parenthesize("list", expr.Elements...)
+}
+
+func (a *AstPrinter) visitMapExpr(expr *MapExpr) interface{} {
+	entries := make([]Expr, 0, 2*len(expr.Keys))
+	for index, key := range expr.Keys {
+		entries = append(entries, key, expr.Values[index])
+	}
+	return a.parenthesize("map", entries...)
+}
+
+func (a *AstPrinter) visitSpawnExpr(expr *SpawnExpr) interface{} {
+	return a.parenthesize("spawn", expr.Call)
+}
+
+func (a *AstPrinter) visitAwaitExpr(expr *AwaitExpr) interface{} {
+	return a.parenthesize("await", expr.Value)
+}
+
+func (a *AstPrinter) visitMatchExpr(expr *MatchExpr) interface{} {
+	return a.printMatch(expr.Subject, expr.Arms)
+}
+
+func (a *AstPrinter) visitOptionalChainExpr(expr *OptionalChainExpr) interface{} {
+	return a.printExpr(expr.Expression)
+}
+
 // Helper methods
 func (a *AstPrinter) printExpr(expr Expr) string {
     if expr == nil {
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Tasks started with `spawn` run on their own goroutines, each with its own Interpreter,
+// so the current environment of one task never leaks into another. Globals, closures and
+// objects are shared between tasks, but only one task runs Lox code at a time: the
+// running task holds loxLock, and gives it up while it waits on a channel or another
+// task, and every preemptInterval statements. Environments, instances, lists and maps
+// therefore need no locking of their own.
+var loxLock sync.Mutex
+
+// liveTasks counts the spawned tasks that haven't finished. Preemption is only worth it
+// while there are some.
+var liveTasks atomic.Int64
+
+// spawnedTasks lists every task in the order it was spawned. The script only ends once
+// all of them have finished, see waitForTasks.
+var spawnedTasks []*LoxTask
+
+// waitedTasks counts the leading spawnedTasks that waitForTasks already waited for
+var waitedTasks int
+
+// preemptInterval is how many statements a task runs before letting other tasks in
+const preemptInterval = 1000
+
+// blocking runs wait, which waits on other tasks, without holding loxLock
+func blocking(wait func()) {
+	loxLock.Unlock()
+	defer loxLock.Lock()
+	wait()
+}
+
+// parkedTasks counts the goroutines waiting in parked, and wakeups how many of those
+// waits ended. Once the main script and every live task are parked and none wakes up,
+// they wait on each other or on nobody, and will never finish.
+var parkedTasks atomic.Int64
+var wakeups atomic.Int64
+
+// errDeadlock is raised where the main script waits while everything else waits too
+var errDeadlock = &NativeError{Message: "Deadlock: every task is waiting."}
+
+// parked is blocking for waits on a channel or another task, which only end when some
+// other goroutine acts, unlike a timer. It returns what wait returns. When the main
+// script parks, wait also gets a channel that is closed once nothing can wake it up
+// anymore, and should then return false. Tasks get a nil channel and wait for good,
+// leaving it to the main script to notice.
+func parked(interpreter *Interpreter, wait func(stuck <-chan struct{}) bool) (woken bool) {
+	parkedTasks.Add(1)
+	blocking(func() {
+		defer func() {
+			wakeups.Add(1)
+			parkedTasks.Add(-1)
+		}()
+		if interpreter.spawned {
+			woken = wait(nil)
+			return
+		}
+		stuck, done := make(chan struct{}), make(chan struct{})
+		defer close(done)
+		go watchForDeadlock(stuck, done)
+		woken = wait(stuck)
+	})
+	return woken
+}
+
+// stuckCheckInterval is how often a parked main script checks whether the tasks are stuck
+const stuckCheckInterval = 10 * time.Millisecond
+
+// watchForDeadlock closes stuck once the main script and every live task are parked for
+// two checks in a row without any of them waking up, unless done is closed first
+func watchForDeadlock(stuck chan<- struct{}, done <-chan struct{}) {
+	ticker := time.NewTicker(stuckCheckInterval)
+	defer ticker.Stop()
+	lastWakeups := int64(-1)
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if parkedTasks.Load() != liveTasks.Load()+1 {
+				lastWakeups = -1
+				continue
+			}
+			if current := wakeups.Load(); current != lastWakeups {
+				lastWakeups = current
+				continue
+			}
+			close(stuck)
+			return
+		}
+	}
+}
+
+// preempt lets other tasks run from time to time, so a task computing without ever
+// blocking can't starve them
+func (i *Interpreter) preempt() {
+	i.steps++
+	if i.steps%preemptInterval != 0 || liveTasks.Load() == 0 {
+		return
+	}
+	loxLock.Unlock()
+	runtime.Gosched()
+	loxLock.Lock()
+}
+
+// fork returns an interpreter for code running on another goroutine. It shares the
+// globals and the resolved variables of the program, but has its own environment chain.
+func (i *Interpreter) fork() *Interpreter {
+	return &Interpreter{
+		globals:     i.globals,
+		environment: i.globals,
+		locals:      i.locals,
+		loop:        i.loop,
+		noTailCalls: i.noTailCalls,
+		spawned:     i.spawned,
+	}
+}
+
+// LoxTask is the handle `spawn` returns for a function running concurrently
+type LoxTask struct {
+	name   string
+	done   chan struct{}
+	result interface{}
+	// err is the runtime error the task failed with, raised again by `await`
+	err interface{}
+	// awaited is set once the task was awaited, which takes over reporting err
+	awaited bool
+}
+
+func NewLoxTask(name string) *LoxTask {
+	return &LoxTask{
+		name: name,
+		done: make(chan struct{}),
+	}
+}
+
+// spawn calls function with arguments on a new goroutine and interpreter
+func (i *Interpreter) spawn(function LoxCallable, arguments []interface{}, paren Token) *LoxTask {
+	name := "task"
+	if loxFunction, ok := function.(*LoxFunction); ok {
+		name = loxFunction.Declaration.Name.Lexeme
+	}
+	task := NewLoxTask(name)
+	interpreter := i.fork()
+	interpreter.spawned = true
+
+	liveTasks.Add(1)
+	spawnedTasks = append(spawnedTasks, task)
+	go func() {
+		loxLock.Lock()
+		defer loxLock.Unlock()
+		defer liveTasks.Add(-1)
+		task.run(interpreter, function, arguments, paren)
+	}()
+	return task
+}
+
+func (t *LoxTask) run(interpreter *Interpreter, function LoxCallable, arguments []interface{}, paren Token) {
+	defer close(t.done)
+	defer func() {
+		if r := recover(); r != nil {
+			switch err := r.(type) {
+			case RuntimeError:
+				t.err = err
+			case *NativeError:
+				t.err = RuntimeError{Token: paren, Message: err.Message}
+			default:
+				panic(r)
+			}
+		}
+	}()
+
+	t.result = function.call(interpreter, arguments)
+}
+
+// await blocks until the task finishes and returns its result, raising its error if it
+// failed
+func (t *LoxTask) await(interpreter *Interpreter) interface{} {
+	t.awaited = true
+	if !t.wait(interpreter) {
+		panic(errDeadlock)
+	}
+	if t.err != nil {
+		panic(t.err)
+	}
+	return t.result
+}
+
+// waitForTasks waits for the spawned tasks that are still running, including the ones
+// they spawn in turn, and reports whether there were any. Tasks that are stuck waiting on
+// channels or on each other are abandoned, so the script can still end.
+func waitForTasks(interpreter *Interpreter) bool {
+	waited := false
+	for waitedTasks < len(spawnedTasks) {
+		if !spawnedTasks[waitedTasks].wait(interpreter) {
+			return false
+		}
+		waitedTasks++
+		waited = true
+	}
+	return waited
+}
+
+// wait waits for the task to finish, and reports whether it did. It returns false when
+// the main script would wait forever, see parked.
+func (t *LoxTask) wait(interpreter *Interpreter) bool {
+	return parked(interpreter, func(stuck <-chan struct{}) bool {
+		select {
+		case <-t.done:
+			return true
+		case <-stuck:
+			return false
+		}
+	})
+}
+
+// reportUnawaitedFailures reports the errors of failed tasks nobody awaited, which
+// would otherwise go unnoticed
+func reportUnawaitedFailures() {
+	for _, task := range spawnedTasks {
+		if task.err != nil && !task.awaited {
+			handleRuntimeError(task.err.(RuntimeError))
+		}
+	}
+}
+
+func (t *LoxTask) String() string {
+	return fmt.Sprintf("<task %s>", t.name)
+}
+
+// LoxChannel is what the `Channel()` native returns, a Go channel carrying Lox values
+type LoxChannel struct {
+	channel chan interface{}
+	closed  bool
+}
+
+func NewLoxChannel(capacity int) *LoxChannel {
+	return &LoxChannel{
+		channel: make(chan interface{}, capacity),
+	}
+}
+
+// NewChannelNative is the `Channel([capacity])` native. Without a capacity sends wait
+// for a receiver.
+func NewChannelNative() *NativeFunction {
+	return &NativeFunction{Name: "Channel", Min: 0, Max: 1, Function: func(interpreter *Interpreter, arguments []interface{}) interface{} {
+		if len(arguments) == 0 {
+			return NewLoxChannel(0)
+		}
+		capacity, ok := arguments[0].(int64)
+		if !ok || capacity < 0 || capacity > maxChannelCapacity {
+			panic(&NativeError{Message: "Channel capacity must be a non-negative integer."})
+		}
+		return NewLoxChannel(int(capacity))
+	}}
+}
+
+const maxChannelCapacity = 1 << 20
+
+// send waits until value is taken by a receiver, or fits in the buffer
+func (c *LoxChannel) send(interpreter *Interpreter, value interface{}) {
+	if c.closed {
+		panic(&NativeError{Message: "Can't send on a closed channel."})
+	}
+	sent := parked(interpreter, func(stuck <-chan struct{}) bool {
+		defer recoverClosedChannel()
+		select {
+		case c.channel <- value:
+			return true
+		case <-stuck:
+			return false
+		}
+	})
+	if !sent {
+		panic(errDeadlock)
+	}
+}
+
+// receive waits for a value. Once the channel is closed and drained it returns nil.
+func (c *LoxChannel) receive(interpreter *Interpreter) (value interface{}, ok bool) {
+	received := parked(interpreter, func(stuck <-chan struct{}) bool {
+		select {
+		case value, ok = <-c.channel:
+			return true
+		case <-stuck:
+			return false
+		}
+	})
+	if !received {
+		panic(errDeadlock)
+	}
+	return value, ok
+}
+
+func (c *LoxChannel) close() {
+	if c.closed {
+		panic(&NativeError{Message: "Channel is already closed."})
+	}
+	c.closed = true
+	close(c.channel)
+}
+
+// recoverClosedChannel turns Go's panic for a send on a channel closed while the sender
+// was waiting into a NativeError. It has to be deferred directly.
+func recoverClosedChannel() {
+	if r := recover(); r != nil {
+		if err, ok := r.(runtime.Error); ok && err.Error() == "send on closed channel" {
+			panic(&NativeError{Message: "Can't send on a closed channel."})
+		}
+		panic(r)
+	}
+}
+
+func (c *LoxChannel) get(name Token) interface{} {
+	switch name.Lexeme {
+	case "send":
+		return NewNativeFunction("send", 1, func(interpreter *Interpreter, arguments []interface{}) interface{} {
+			c.send(interpreter, arguments[0])
+			return nil
+		})
+	case "receive":
+		return NewNativeFunction("receive", 0, func(interpreter *Interpreter, arguments []interface{}) interface{} {
+			value, _ := c.receive(interpreter)
+			return value
+		})
+	case "close":
+		return NewNativeFunction("close", 0, func(interpreter *Interpreter, arguments []interface{}) interface{} {
+			c.close()
+			return nil
+		})
+	}
+
+	panic(RuntimeError{
+		Token:   name,
+		Message: fmt.Sprintf("Undefined property %s.", name.Lexeme),
+	})
+}
+
+func (c *LoxChannel) String() string {
+	return "<channel>"
+}
+
+// channelIterator receives until the channel is closed and drained
+type channelIterator struct {
+	interpreter *Interpreter
+	channel     *LoxChannel
+	// token is where a deadlock while receiving is reported
+	token    Token
+	value    interface{}
+	buffered bool
+	done     bool
+}
+
+func (c *channelIterator) hasNext() bool {
+	if !c.buffered && !c.done {
+		defer c.interpreter.blameNativeError(c.token)
+		value, ok := c.channel.receive(c.interpreter)
+		c.value, c.buffered, c.done = value, ok, !ok
+	}
+	return !c.done
+}
+
+func (c *channelIterator) next() interface{} {
+	c.hasNext()
+	c.buffered = false
+	return c.value
+}
+
+func (i *Interpreter) visitSelectStmt(stmt *SelectStatement) interface{} {
+	cases := make([]reflect.SelectCase, 0, len(stmt.Cases))
+	for _, selectCase := range stmt.Cases {
+		switch selectCase.Kind.Lexeme {
+		case "receive":
+			channel := i.evaluateChannel(selectCase.Channel, selectCase.Kind)
+			cases = append(cases, reflect.SelectCase{
+				Dir:  reflect.SelectRecv,
+				Chan: reflect.ValueOf(channel.channel),
+			})
+		case "send":
+			channel := i.evaluateChannel(selectCase.Channel, selectCase.Kind)
+			value := i.evaluate(selectCase.Value)
+			if channel.closed {
+				panic(RuntimeError{Token: selectCase.Kind, Message: "Can't send on a closed channel."})
+			}
+			cases = append(cases, reflect.SelectCase{
+				Dir:  reflect.SelectSend,
+				Chan: reflect.ValueOf(channel.channel),
+				Send: reflect.ValueOf(&value).Elem(),
+			})
+		default:
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+		}
+	}
+
+	var chosen int
+	var received reflect.Value
+	func() {
+		defer i.blameNativeError(stmt.Keyword)
+		selected := parked(i, func(stuck <-chan struct{}) bool {
+			defer recoverClosedChannel()
+			chosen, received, _ = reflect.Select(append(cases, reflect.SelectCase{
+				Dir:  reflect.SelectRecv,
+				Chan: reflect.ValueOf(stuck),
+			}))
+			return chosen < len(cases)
+		})
+		if !selected {
+			panic(errDeadlock)
+		}
+	}()
+
+	selected := stmt.Cases[chosen]
+	environment := NewEnclosedEnvironment(i.environment)
+	if selected.Name != nil {
+		environment.define(selected.Name.Lexeme, received.Interface())
+	}
+	i.executeBlock(selected.Body, environment)
+	return nil
+}
+
+func (i *Interpreter) evaluateChannel(expr Expr, token Token) *LoxChannel {
+	channel, ok := i.evaluate(expr).(*LoxChannel)
+	if !ok {
+		panic(RuntimeError{
+			Token:   token,
+			Message: "Can only select on channels.",
+		})
+	}
+	return channel
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// DiagnosticFormat selects how diagnostics are written out
+type DiagnosticFormat string
+
+const (
+	DIAGNOSTICS_TEXT  DiagnosticFormat = "text"
+	DIAGNOSTICS_JSON  DiagnosticFormat = "json"
+	DIAGNOSTICS_SARIF DiagnosticFormat = "sarif"
+)
+
+// Severity is the importance of a diagnostic
+type Severity string
+
+const (
+	SEVERITY_ERROR   Severity = "error"
+	SEVERITY_WARNING Severity = "warning"
+)
+
+// DiagnosticCode identifies the kind of problem, one per compiler phase for errors
+type DiagnosticCode string
+
+const (
+	CODE_SCAN_ERROR    DiagnosticCode = "LOX100"
+	CODE_PARSE_ERROR   DiagnosticCode = "LOX200"
+	CODE_RESOLVE_ERROR DiagnosticCode = "LOX300"
+	CODE_MATCH_WARNING DiagnosticCode = "LOX301"
+	CODE_RUNTIME_ERROR DiagnosticCode = "LOX400"
+	CODE_TYPE_ERROR    DiagnosticCode = "LOX500"
+)
+
+var diagnosticRules = map[DiagnosticCode]string{
+	CODE_SCAN_ERROR:    "Scan error",
+	CODE_PARSE_ERROR:   "Syntax error",
+	CODE_RESOLVE_ERROR: "Resolution error",
+	CODE_MATCH_WARNING: "Non-exhaustive match",
+	CODE_RUNTIME_ERROR: "Runtime error",
+	CODE_TYPE_ERROR:    "Type error",
+}
+
+// Location points at a position in a source file. Columns are 1-based, 0 means unknown.
+type Location struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column,omitempty"`
+}
+
+// RelatedLocation is a secondary location that helps explain a diagnostic
+type RelatedLocation struct {
+	Location
+	Message string `json:"message"`
+}
+
+// Diagnostic is a single problem found in a Lox program
+type Diagnostic struct {
+	Severity Severity       `json:"severity"`
+	Code     DiagnosticCode `json:"code"`
+	Location
+	Message string            `json:"message"`
+	Related []RelatedLocation `json:"related,omitempty"`
+}
+
+// DiagnosticReporter collects diagnostics. In text mode they are printed as soon as they
+// are reported, otherwise they are buffered and written as one document by flush.
+type DiagnosticReporter struct {
+	format      DiagnosticFormat
+	file        string
+	out         io.Writer
+	diagnostics []Diagnostic
+}
+
+var reporter = NewDiagnosticReporter(DIAGNOSTICS_TEXT, "")
+
+func NewDiagnosticReporter(format DiagnosticFormat, file string) *DiagnosticReporter {
+	return &DiagnosticReporter{
+		format:      format,
+		file:        file,
+		out:         os.Stderr,
+		diagnostics: make([]Diagnostic, 0),
+	}
+}
+
+// add records a diagnostic. text is the human readable rendering used in text mode.
+func (d *DiagnosticReporter) add(diagnostic Diagnostic, text string) {
+	diagnostic.File = d.file
+	for index := range diagnostic.Related {
+		diagnostic.Related[index].File = d.file
+	}
+	d.diagnostics = append(d.diagnostics, diagnostic)
+
+	if d.format == DIAGNOSTICS_TEXT {
+		fmt.Fprint(d.out, text)
+	}
+}
+
+// flush writes the buffered diagnostics in the machine readable formats
+func (d *DiagnosticReporter) flush() {
+	var document interface{}
+	switch d.format {
+	case DIAGNOSTICS_JSON:
+		document = map[string]interface{}{
+			"diagnostics": d.diagnostics,
+		}
+	case DIAGNOSTICS_SARIF:
+		document = d.sarif()
+	default:
+		return
+	}
+
+	encoder := json.NewEncoder(d.out)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(document)
+	d.diagnostics = d.diagnostics[:0]
+}
+
+func (d *DiagnosticReporter) sarif() interface{} {
+	usedRules := make(map[DiagnosticCode]bool)
+	results := make([]interface{}, 0, len(d.diagnostics))
+
+	for _, diagnostic := range d.diagnostics {
+		usedRules[diagnostic.Code] = true
+
+		related := make([]interface{}, 0, len(diagnostic.Related))
+		for index, location := range diagnostic.Related {
+			related = append(related, map[string]interface{}{
+				"id":               index,
+				"message":          map[string]string{"text": location.Message},
+				"physicalLocation": sarifPhysicalLocation(location.Location),
+			})
+		}
+
+		result := map[string]interface{}{
+			"ruleId":  string(diagnostic.Code),
+			"level":   string(diagnostic.Severity),
+			"message": map[string]string{"text": diagnostic.Message},
+			"locations": []interface{}{
+				map[string]interface{}{"physicalLocation": sarifPhysicalLocation(diagnostic.Location)},
+			},
+		}
+		if len(related) > 0 {
+			result["relatedLocations"] = related
+		}
+		results = append(results, result)
+	}
+
+	codes := make([]string, 0, len(usedRules))
+	for code := range usedRules {
+		codes = append(codes, string(code))
+	}
+	sort.Strings(codes)
+
+	rules := make([]interface{}, 0, len(codes))
+	for _, code := range codes {
+		rules = append(rules, map[string]interface{}{
+			"id":               code,
+			"shortDescription": map[string]string{"text": diagnosticRules[DiagnosticCode(code)]},
+		})
+	}
+
+	return map[string]interface{}{
+		"version": "2.1.0",
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"runs": []interface{}{
+			map[string]interface{}{
+				"tool": map[string]interface{}{
+					"driver": map[string]interface{}{
+						"name":  "lox",
+						"rules": rules,
+					},
+				},
+				"results": results,
+			},
+		},
+	}
+}
+
+func sarifPhysicalLocation(location Location) map[string]interface{} {
+	region := map[string]interface{}{
+		"startLine": location.Line,
+	}
+	if location.Column > 0 {
+		region["startColumn"] = location.Column
+	}
+	return map[string]interface{}{
+		"artifactLocation": map[string]string{"uri": location.File},
+		"region":           region,
+	}
+}
+
+func parseDiagnosticFormat(value string) (DiagnosticFormat, bool) {
+	switch DiagnosticFormat(value) {
+	case DIAGNOSTICS_TEXT, DIAGNOSTICS_JSON, DIAGNOSTICS_SARIF:
+		return DiagnosticFormat(value), true
+	}
+	return "", false
+}
@@ -5,6 +5,9 @@ import "fmt"
 type Environment struct {
 	values    map[string]interface{}
 	enclosing *Environment
+	// constants names the `const` bindings. The resolver rejects assignments to local
+	// constants, so only globals are checked here.
+	constants map[string]bool
 }
 
 func NewEnvironment() *Environment {
@@ -28,6 +31,26 @@ func (e *Environment) define(name string, value interface{}) {
 	e.values[name] = value
 }
 
+// declare defines a variable the program declares, which can't take the place of a
+// constant. The resolver reports this for locals, so only globals are checked here.
+func (e *Environment) declare(name Token, value interface{}) {
+	if e.constants[name.Lexeme] {
+		panic(RuntimeError{
+			Token:   name,
+			Message: "Can't redeclare constant '" + name.Lexeme + "'.",
+		})
+	}
+	e.define(name.Lexeme, value)
+}
+
+func (e *Environment) declareConstant(name Token, value interface{}) {
+	e.declare(name, value)
+	if e.constants == nil {
+		e.constants = make(map[string]bool)
+	}
+	e.constants[name.Lexeme] = true
+}
+
 func (e *Environment) get(name Token) interface{} {
 
 	if value, ok := e.values[name.Lexeme]; ok {
@@ -58,6 +81,12 @@ func (e *Environment) ancestor(distance int) *Environment {
 
 func (e *Environment) assign(name Token, value interface{}) {
 	if _, ok := e.values[name.Lexeme]; ok {
+		if e.constants[name.Lexeme] {
+			panic(RuntimeError{
+				Token:   name,
+				Message: "Can't assign to constant '" + name.Lexeme + "'.",
+			})
+		}
 		e.values[name.Lexeme] = value
 		return
 	}
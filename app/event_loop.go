@@ -0,0 +1,213 @@
+package main
+
+import (
+	"container/heap"
+	"time"
+)
+
+// EventLoop runs timer callbacks and the continuations of async functions, one at a
+// time, once the main script is done or while it awaits a promise. Timers fire in order
+// of their due time, then of when they were scheduled. Due times are measured on a
+// logical clock: a timer set from a callback is due relative to that callback's own due
+// time, not to the wall clock, so callbacks run in the same order on every run. With a
+// fake clock the loop jumps straight to the next due time instead of sleeping.
+type EventLoop struct {
+	fake  bool
+	start time.Time
+	// now is the logical time elapsed since the loop was created
+	now time.Duration
+
+	microtasks []func()
+	timers     timerQueue
+	active     map[int64]*timer
+	nextID     int64
+	sequence   int64
+
+	// rejected holds rejected promises, reported at the end unless something awaited them
+	rejected []*LoxPromise
+}
+
+type timer struct {
+	id       int64
+	due      time.Duration
+	sequence int64
+	// interval is how often a setInterval timer repeats, 0 for setTimeout
+	interval time.Duration
+	callback func(interpreter *Interpreter)
+}
+
+func NewEventLoop() *EventLoop {
+	return &EventLoop{
+		start:      time.Now(),
+		microtasks: make([]func(), 0),
+		timers:     make(timerQueue, 0),
+		active:     make(map[int64]*timer),
+	}
+}
+
+// enqueue runs task as soon as the code running now is done, before any timer
+func (l *EventLoop) enqueue(task func()) {
+	l.microtasks = append(l.microtasks, task)
+}
+
+func (l *EventLoop) setTimer(callback func(interpreter *Interpreter), delay time.Duration, repeat bool) int64 {
+	l.nextID++
+	t := &timer{
+		id:       l.nextID,
+		due:      l.now + delay,
+		callback: callback,
+	}
+	if repeat {
+		t.interval = max(delay, time.Millisecond)
+	}
+	l.active[t.id] = t
+	l.schedule(t)
+	return t.id
+}
+
+func (l *EventLoop) schedule(t *timer) {
+	l.sequence++
+	t.sequence = l.sequence
+	heap.Push(&l.timers, t)
+}
+
+func (l *EventLoop) clearTimer(id int64) {
+	delete(l.active, id)
+}
+
+// run runs callbacks until done returns true, or nothing is left to run. It reports
+// whether done was reached.
+func (l *EventLoop) run(interpreter *Interpreter, done func() bool) bool {
+	for !done() {
+		if len(l.microtasks) > 0 {
+			task := l.microtasks[0]
+			l.microtasks = l.microtasks[1:]
+			task()
+			continue
+		}
+
+		if l.timers.Len() == 0 {
+			return false
+		}
+		next := heap.Pop(&l.timers).(*timer)
+		if l.active[next.id] != next {
+			continue
+		}
+
+		l.advance(next.due)
+		if next.interval > 0 {
+			next.due += next.interval
+			l.schedule(next)
+		} else {
+			delete(l.active, next.id)
+		}
+		next.callback(interpreter)
+	}
+	return true
+}
+
+// advance moves the logical clock forward to to, waiting for the wall clock to catch
+// up unless the clock is fake
+func (l *EventLoop) advance(to time.Duration) {
+	if to <= l.now {
+		return
+	}
+	if !l.fake {
+		if wait := time.Until(l.start.Add(to)); wait > 0 {
+			blocking(func() {
+				time.Sleep(wait)
+			})
+		}
+	}
+	l.now = to
+}
+
+// reportUnhandledRejections reports the errors of async calls nobody awaited
+func (l *EventLoop) reportUnhandledRejections() {
+	for _, promise := range l.rejected {
+		if !promise.handled {
+			handleRuntimeError(promise.err.(RuntimeError))
+		}
+	}
+	l.rejected = l.rejected[:0]
+}
+
+// timerQueue orders timers by due time, then by when they were scheduled
+type timerQueue []*timer
+
+func (q timerQueue) Len() int {
+	return len(q)
+}
+
+func (q timerQueue) Less(i, j int) bool {
+	if q[i].due != q[j].due {
+		return q[i].due < q[j].due
+	}
+	return q[i].sequence < q[j].sequence
+}
+
+func (q timerQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *timerQueue) Push(value any) {
+	*q = append(*q, value.(*timer))
+}
+
+func (q *timerQueue) Pop() any {
+	old := *q
+	last := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return last
+}
+
+// NewTimerNative is `setTimeout(fn, ms)`, or `setInterval(fn, ms)` when repeat is set.
+// Both return an id for clearTimeout and clearInterval. fn may be any callable that can
+// be called without arguments, and errors of natives are reported at the call setting
+// the timer.
+func NewTimerNative(name string, repeat bool) *NativeFunction {
+	return NewNativeFunction(name, 2, func(interpreter *Interpreter, arguments []interface{}) interface{} {
+		callback, ok := arguments[0].(LoxCallable)
+		if !ok {
+			panic(&NativeError{Message: name + " expects a function without parameters."})
+		}
+		if min, _ := callback.arity(); min > 0 {
+			panic(&NativeError{Message: name + " expects a function without parameters."})
+		}
+		site := interpreter.nativeCall
+		run := func(interpreter *Interpreter) {
+			defer interpreter.blameNativeError(site)
+			callback.call(interpreter, []interface{}{})
+		}
+		return interpreter.loop.setTimer(run, durationArgument(name, arguments[1]), repeat)
+	})
+}
+
+// NewClearTimerNative is `clearTimeout(id)` and `clearInterval(id)`
+func NewClearTimerNative(name string) *NativeFunction {
+	return NewNativeFunction(name, 1, func(interpreter *Interpreter, arguments []interface{}) interface{} {
+		if id, ok := arguments[0].(int64); ok {
+			interpreter.loop.clearTimer(id)
+		}
+		return nil
+	})
+}
+
+// NewSleepNative is `sleep(ms)`, which returns a promise fulfilled after ms
+func NewSleepNative() *NativeFunction {
+	return NewNativeFunction("sleep", 1, func(interpreter *Interpreter, arguments []interface{}) interface{} {
+		promise := NewLoxPromise(interpreter.loop)
+		wake := func(interpreter *Interpreter) {
+			promise.resolve(nil)
+		}
+		interpreter.loop.setTimer(wake, durationArgument("sleep", arguments[0]), false)
+		return promise
+	})
+}
+
+func durationArgument(name string, value interface{}) time.Duration {
+	if !isNumber(value) || toFloat(value) < 0 {
+		panic(&NativeError{Message: name + " expects a non-negative number of milliseconds."})
+	}
+	return time.Duration(toFloat(value) * float64(time.Millisecond))
+}
@@ -17,6 +17,17 @@ type ExprVisitor interface {
 	visitSetExpr(expr *SetExpression) interface{} 
 	visitThisExpr(expr *ThisExpr) interface{}
 	visitSuperExpr(expr *SuperExpr) interface{} 
+	visitInterpolationExpr(expr *InterpolationExpr) interface{}
+	visitCompoundAssignmentExpr(expr *CompoundAssignmentExpr) interface{}
+	visitConditionalExpr(expr *ConditionalExpr) interface{}
+	visitOptionalChainExpr(expr *OptionalChainExpr) interface{}
+	visitIndexExpr(expr *IndexExpr) interface{}
+	visitIndexSetExpr(expr *IndexSetExpr) interface{}
+	visitListExpr(expr *ListExpr) interface{}
+	visitMapExpr(expr *MapExpr) interface{}
+	visitSpawnExpr(expr *SpawnExpr) interface{}
+	visitAwaitExpr(expr *AwaitExpr) interface{}
+	visitMatchExpr(expr *MatchExpr) interface{}
 }
 
 type BinaryExpr struct {
@@ -56,12 +67,17 @@ type AssignmentExpr struct {
 type CallExpression struct {
 	Callee Expr 
 	Arguments []Expr 
+	// Names and NamedArguments hold the `name: value` arguments following the positional ones
+	Names          []Token
+	NamedArguments []Expr
 	Parenthesis Token 
 }
 
 type GetExpression struct {
 	Name Token 
 	Object Expr
+	// Optional is set for `?.`, which yields nil instead of failing on a nil object
+	Optional bool
 }
 
 type SetExpression struct {
@@ -79,6 +95,81 @@ type SuperExpr struct {
 	Method Token 
 }
 
+// InterpolationExpr concatenates the string parts and embedded expressions of an
+// interpolated string literal
+type InterpolationExpr struct {
+	Parts []Expr
+}
+
+// CompoundAssignmentExpr covers `target op= value` as well as prefix and postfix `++`/`--`,
+// which use a value of 1. Target is a *VariableExpr, *GetExpression or *IndexExpr, and Operator has
+// the type of the underlying binary operator while keeping the original lexeme.
+type CompoundAssignmentExpr struct {
+	Target   Expr
+	Operator Token
+	Value    Expr
+	Postfix  bool
+}
+
+// ConditionalExpr is `Condition ? ThenBranch : ElseBranch`
+type ConditionalExpr struct {
+	Condition  Expr
+	ThenBranch Expr
+	ElseBranch Expr
+}
+
+// OptionalChainExpr wraps a chain of calls and property accesses containing `?.`, so the
+// whole chain evaluates to nil once an optional access finds a nil object
+type OptionalChainExpr struct {
+	Expression Expr
+}
+
+// IndexExpr is `Object[Index]`
+type IndexExpr struct {
+	Object  Expr
+	Index   Expr
+	Bracket Token
+}
+
+// IndexSetExpr is `Object[Index] = Value`
+type IndexSetExpr struct {
+	Object  Expr
+	Index   Expr
+	Value   Expr
+	Bracket Token
+}
+
+// ListExpr is a list literal `[a, b, c]`
+type ListExpr struct {
+	Elements []Expr
+}
+
+// MapExpr is a map literal `{key: value, ...}`. Keys are expressions, evaluated in order.
+type MapExpr struct {
+	Keys   []Expr
+	Values []Expr
+}
+
+// SpawnExpr is `spawn call`, which runs the call concurrently and yields a task
+type SpawnExpr struct {
+	Keyword Token
+	Call    *CallExpression
+}
+
+// AwaitExpr is `await value`, which waits for a task and yields its result
+type AwaitExpr struct {
+	Keyword Token
+	Value   Expr
+}
+
+// MatchExpr is `match (subject) { pattern [if guard] => value, ... }`, the value of the
+// first arm whose pattern matches
+type MatchExpr struct {
+	Keyword Token
+	Subject Expr
+	Arms    []*MatchArm
+}
+
 func (e *BinaryExpr) Accept(visitor ExprVisitor) interface{} {
 	return visitor.visitBinaryExpr(e)
 }
@@ -126,3 +217,47 @@ func (e *ThisExpr) Accept(visitor ExprVisitor) interface{} {
 func (e *SuperExpr) Accept(visitor ExprVisitor) interface{} {
 	return visitor.visitSuperExpr(e)
 }
+
+func (e *InterpolationExpr) Accept(visitor ExprVisitor) interface{} {
+	return visitor.visitInterpolationExpr(e)
+}
+
+func (e *CompoundAssignmentExpr) Accept(visitor ExprVisitor) interface{} {
+	return visitor.visitCompoundAssignmentExpr(e)
+}
+
+func (e *ConditionalExpr) Accept(visitor ExprVisitor) interface{} {
+	return visitor.visitConditionalExpr(e)
+}
+
+func (e *OptionalChainExpr) Accept(visitor ExprVisitor) interface{} {
+	return visitor.visitOptionalChainExpr(e)
+}
+
+func (e *IndexExpr) Accept(visitor ExprVisitor) interface{} {
+	return visitor.visitIndexExpr(e)
+}
+
+func (e *IndexSetExpr) Accept(visitor ExprVisitor) interface{} {
+	return visitor.visitIndexSetExpr(e)
+}
+
+func (e *ListExpr) Accept(visitor ExprVisitor) interface{} {
+	return visitor.visitListExpr(e)
+}
+
+func (e *MapExpr) Accept(visitor ExprVisitor) interface{} {
+	return visitor.visitMapExpr(e)
+}
+
+func (e *SpawnExpr) Accept(visitor ExprVisitor) interface{} {
+	return visitor.visitSpawnExpr(e)
+}
+
+func (e *AwaitExpr) Accept(visitor ExprVisitor) interface{} {
+	return visitor.visitAwaitExpr(e)
+}
+
+func (e *MatchExpr) Accept(visitor ExprVisitor) interface{} {
+	return visitor.visitMatchExpr(e)
+}
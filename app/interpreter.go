@@ -2,7 +2,7 @@ package main
 
 import (
 	"fmt"
-	"os"
+	"math/big"
 	"reflect"
 	"strings"
 )
@@ -12,13 +12,52 @@ type Interpreter struct {
 	globals                *Environment
 	environment            *Environment
 	locals                 map[Expr]int
+	// generator is the generator whose body this interpreter runs, nil outside of one
+	generator *LoxGenerator
+	// async is the coroutine of the async call whose body this interpreter runs
+	async *coroutine
+	loop  *EventLoop
+	// steps counts executed statements, to preempt long running tasks
+	steps int
+	// noTailCalls turns off tail call optimization, so every call keeps its own frame
+	noTailCalls bool
+	// spawned is set for interpreters running a spawned task, whose waits leave noticing
+	// a deadlock to the main script
+	spawned bool
+	// nativeCall is the call of the latest native, for natives whose callbacks run later
+	nativeCall Token
 }
 
+// maxShift bounds shift counts, and the number of bits a power may grow to, so a stray
+// shift or ** can't allocate an enormous integer
+const maxShift = 1 << 20
+
 type ReturnValue struct {
 	Value interface{}
+	// TailCall is the call a `return f(x);` ends with, left for the caller to make so
+	// the returning frame is gone by then
+	TailCall *tailCall
+}
+
+type tailCall struct {
+	function  *LoxFunction
+	arguments []interface{}
+}
+
+// result is the returned value, making the pending tail call if there is one
+func (r *ReturnValue) result(interpreter *Interpreter) interface{} {
+	if r.TailCall != nil {
+		return r.TailCall.function.call(interpreter, r.TailCall.arguments)
+	}
+	return r.Value
 }
 
+// optionalChainNil unwinds an optional chain up to its OptionalChainExpr once `?.` meets nil
+type optionalChainNil struct{}
+
 func (i *Interpreter) interpret(statements []Stmt) {
+	loxLock.Lock()
+	defer loxLock.Unlock()
 	defer func() {
 		if r := recover(); r != nil {
 			if runtimeErr, ok := r.(RuntimeError); ok && !hasError {
@@ -32,6 +71,19 @@ func (i *Interpreter) interpret(statements []Stmt) {
 	for _, statement := range statements {
 		i.execute(statement)
 	}
+
+	// The script ends once its timers have fired and the tasks it spawned have finished,
+	// which may in turn schedule more timers
+	i.loop.run(i, func() bool {
+		return false
+	})
+	for waitForTasks(i) {
+		i.loop.run(i, func() bool {
+			return false
+		})
+	}
+	i.loop.reportUnhandledRejections()
+	reportUnawaitedFailures()
 }
 
 func (i *Interpreter) resolve(expr Expr, depth int) {
@@ -39,6 +91,7 @@ func (i *Interpreter) resolve(expr Expr, depth int) {
 }
 
 func (i *Interpreter) execute(statement Stmt) {
+	i.preempt()
 	statement.Accept(i)
 }
 
@@ -48,15 +101,25 @@ func (i *Interpreter) stringify(object interface{}) string {
 	}
 
 	switch v := object.(type) {
-	case float64:
-		// Format the number to 2 decimal places first
-		formatted := fmt.Sprintf("%.2f", v)
-		// Remove trailing zeros and decimal point if there's no fractional part
-		if strings.Contains(formatted, ".") {
-			formatted = strings.TrimRight(formatted, "0")
-			formatted = strings.TrimRight(formatted, ".")
+	case int64, *big.Int, float64:
+		return formatNumber(object)
+	case *LoxList:
+		elements := make([]string, 0, len(v.Elements))
+		for _, element := range v.Elements {
+			elements = append(elements, i.stringify(element))
 		}
-		return formatted
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *LoxMap:
+		entries := make([]string, 0, len(v.entries))
+		for _, entry := range v.entries {
+			entries = append(entries, i.stringify(entry.Key)+": "+i.stringify(entry.Value))
+		}
+		return "{" + strings.Join(entries, ", ") + "}"
+	case *LoxInstance:
+		if method := findOperatorMethod(v, "__str__"); method != nil {
+			return i.stringify(method.call(i, []interface{}{}))
+		}
+		return v.String()
 	default:
 		return fmt.Sprintf("%v", object)
 	}
@@ -68,40 +131,80 @@ func (i *Interpreter) visitBinaryExpr(expr *BinaryExpr) interface{} {
 	left := i.evaluate(expr.Left)
 	right := i.evaluate(expr.Right)
 
-	switch expr.Operator.TokenType {
-	case MINUS:
-		i.checkNumberOperands(left, expr.Operator, right)
-		return left.(float64) - right.(float64)
-	case STAR:
-		i.checkNumberOperands(left, expr.Operator, right)
-		return left.(float64) * right.(float64)
-	case SLASH:
-		i.checkNumberOperands(left, expr.Operator, right)
-		return left.(float64) / right.(float64)
+	return i.binaryOperation(expr.Operator, left, right)
+}
+
+// binaryOperation applies a binary operator to already evaluated operands
+func (i *Interpreter) binaryOperation(operator Token, left interface{}, right interface{}) interface{} {
+	if name, overloadable := operatorMethods[operator.TokenType]; overloadable {
+		if method := findOperatorMethod(left, name); method != nil {
+			return method.call(i, []interface{}{right})
+		}
+	}
+
+	switch operator.TokenType {
+	case MINUS, STAR, SLASH:
+		i.checkNumberOperands(left, operator, right)
+		return arithmetic(operator.TokenType, left, right)
+	case STAR_STAR:
+		i.checkNumberOperands(left, operator, right)
+		if powerBits(left, right) > maxShift {
+			panic(RuntimeError{Token: operator, Message: "Exponent too large."})
+		}
+		return arithmetic(STAR_STAR, left, right)
+	case PERCENT:
+		i.checkNumberOperands(left, operator, right)
+		if isZero(right) {
+			panic(RuntimeError{Token: operator, Message: "Modulo by zero."})
+		}
+		return arithmetic(PERCENT, left, right)
+	case DIV:
+		i.checkNumberOperands(left, operator, right)
+		if isZero(right) {
+			panic(RuntimeError{Token: operator, Message: "Division by zero."})
+		}
+		return arithmetic(DIV, left, right)
+	case AMPERSAND, PIPE, CARET:
+		leftInt, rightInt := i.checkIntegerOperands(left, operator, right)
+		return bitwise(operator.TokenType, leftInt, rightInt)
+	case LESS_LESS, GREATER_GREATER:
+		leftInt, rightInt := i.checkIntegerOperands(left, operator, right)
+		if rightInt.Sign() < 0 {
+			panic(RuntimeError{Token: operator, Message: "Shift count must not be negative."})
+		}
+		if rightInt.Cmp(big.NewInt(maxShift)) > 0 {
+			panic(RuntimeError{Token: operator, Message: "Shift count is too large."})
+		}
+		return bitwise(operator.TokenType, leftInt, rightInt)
 	case PLUS:
-		if leftNum, leftOk := left.(float64); leftOk {
-			if rightNum, rightOk := right.(float64); rightOk {
-				return leftNum + rightNum
-			}
+		if isNumber(left) && isNumber(right) {
+			return arithmetic(PLUS, left, right)
 		}
 		if leftStr, leftOk := left.(string); leftOk {
 			if rightStr, rightOk := right.(string); rightOk {
 				return leftStr + rightStr
 			}
 		}
-		panic(RuntimeError{Token: expr.Operator, Message: "Operands must be two numbers or two string"})
+		panic(RuntimeError{Token: operator, Message: "Operands must be two numbers or two string"})
 	case GREATER:
-		i.checkNumberOperands(left, expr.Operator, right)
-		return left.(float64) > right.(float64)
+		result, ok := i.compareOperands(left, operator, right)
+		return ok && result > 0
 	case GREATER_EQUAL:
-		i.checkNumberOperands(left, expr.Operator, right)
-		return left.(float64) >= right.(float64)
+		result, ok := i.compareOperands(left, operator, right)
+		return ok && result >= 0
 	case LESS:
-		i.checkNumberOperands(left, expr.Operator, right)
-		return left.(float64) < right.(float64)
+		result, ok := i.compareOperands(left, operator, right)
+		return ok && result < 0
 	case LESS_EQUAL:
-		i.checkNumberOperands(left, expr.Operator, right)
-		return left.(float64) <= right.(float64)
+		result, ok := i.compareOperands(left, operator, right)
+		return ok && result <= 0
+	case DOT_DOT:
+		start, startOk := left.(int64)
+		end, endOk := right.(int64)
+		if !startOk || !endOk {
+			panic(RuntimeError{Token: operator, Message: "Range bounds must be integers."})
+		}
+		return NewLoxRange(start, end)
 	case BANG_EQUAL:
 		return !i.isEqual(left, right)
 	case EQUAL_EQUAL:
@@ -116,10 +219,15 @@ func (i *Interpreter) visitUnaryExpr(expr *UnaryExpr) interface{} {
 
 	switch expr.Operator.TokenType {
 	case MINUS:
+		if method := findOperatorMethod(right, "__neg__"); method != nil {
+			return method.call(i, []interface{}{})
+		}
 		i.checkOperand(expr.Operator, right)
-		return -right.(float64)
+		return negate(right)
 	case BANG:
 		return !i.isTruthy(right)
+	case TILDE:
+		return normalizeInteger(new(big.Int).Not(i.checkIntegerOperand(expr.Operator, right)))
 	}
 
 	return nil
@@ -139,18 +247,52 @@ func (i *Interpreter) visitVariableExpr(expr *VariableExpr) interface{} {
 
 func (i *Interpreter) visitAssignmentExpr(expr *AssignmentExpr) interface{} {
 	value := i.evaluate(expr.Value)
-	distance, exists := i.locals[expr]
-	if exists {
-		i.environment.assignAt(distance, expr.Name, value)
-	} else {
-		i.globals.assign(expr.Name, value)
-	}
+	i.assignVariable(expr.Name, expr, value)
 	return value
 }
 
+func (i *Interpreter) visitCompoundAssignmentExpr(expr *CompoundAssignmentExpr) interface{} {
+	var current, updated interface{}
+
+	switch target := expr.Target.(type) {
+	case *VariableExpr:
+		current = i.lookUpVariable(target.Name, expr)
+		updated = i.binaryOperation(expr.Operator, current, i.evaluate(expr.Value))
+		i.assignVariable(target.Name, expr, updated)
+	case *GetExpression:
+		object := i.evaluate(target.Object)
+		if target.Name.TokenType == PRIVATE_IDENTIFIER {
+			instance := i.privateReceiver(object, target.Name)
+			owner := i.privateOwner(target.Object)
+			current = instance.getPrivate(owner, target.Name)
+			updated = i.binaryOperation(expr.Operator, current, i.evaluate(expr.Value))
+			instance.setPrivate(owner, target.Name, updated)
+			break
+		}
+		current = i.getProperty(object, target.Name)
+		updated = i.binaryOperation(expr.Operator, current, i.evaluate(expr.Value))
+		i.setProperty(object, target.Name, updated)
+	case *IndexExpr:
+		object := i.evaluate(target.Object)
+		index := i.evaluate(target.Index)
+		current = i.indexGet(object, index, target.Bracket)
+		updated = i.binaryOperation(expr.Operator, current, i.evaluate(expr.Value))
+		i.indexSet(object, index, updated, target.Bracket)
+	}
+
+	if expr.Postfix {
+		return current
+	}
+	return updated
+}
+
 func (i *Interpreter) visitLogicalExpr(expr *LogicalExpr) interface{} {
 	leftExpr := i.evaluate(expr.Left)
-	if expr.Operator.TokenType == OR {
+	if expr.Operator.TokenType == QUESTION_QUESTION {
+		if leftExpr != nil {
+			return leftExpr
+		}
+	} else if expr.Operator.TokenType == OR {
 		if i.isTruthy(leftExpr) {
 			return leftExpr
 		}
@@ -163,7 +305,48 @@ func (i *Interpreter) visitLogicalExpr(expr *LogicalExpr) interface{} {
 	return i.evaluate(expr.Right)
 }
 
-func (i *Interpreter) visitCallExpr(expr *CallExpression) interface{} {
+func (i *Interpreter) visitCallExpr(expr *CallExpression) (result interface{}) {
+	function, arguments := i.evaluateCall(expr)
+	return i.callFunction(function, arguments, expr.Parenthesis)
+}
+
+// callFunction calls an evaluated callee, reporting the errors of natives at paren
+func (i *Interpreter) callFunction(function LoxCallable, arguments []interface{}, paren Token) interface{} {
+	if _, ok := function.(*LoxFunction); !ok {
+		defer i.blameNativeError(paren)
+		i.nativeCall = paren
+	}
+
+	return function.call(i, arguments)
+}
+
+func (i *Interpreter) visitSpawnExpr(expr *SpawnExpr) interface{} {
+	function, arguments := i.evaluateCall(expr.Call)
+	return i.spawn(function, arguments, expr.Call.Parenthesis)
+}
+
+func (i *Interpreter) visitAwaitExpr(expr *AwaitExpr) interface{} {
+	switch awaited := i.evaluate(expr.Value).(type) {
+	case *LoxTask:
+		defer i.blameNativeError(expr.Keyword)
+		return awaited.await(i)
+	case *LoxPromise:
+		if i.async != nil {
+			awaited.handled = true
+			return i.async.suspend(awaited)
+		}
+		return i.awaitPromise(awaited, expr.Keyword)
+	}
+
+	panic(RuntimeError{
+		Token:   expr.Keyword,
+		Message: "Can only await tasks and promises.",
+	})
+}
+
+// evaluateCall evaluates the callee and arguments of a call and checks them against
+// the callee's parameters
+func (i *Interpreter) evaluateCall(expr *CallExpression) (LoxCallable, []interface{}) {
 	callee := i.evaluate(expr.Callee)
 
 	arguments := make([]interface{}, 0, len(expr.Arguments))
@@ -179,33 +362,247 @@ func (i *Interpreter) visitCallExpr(expr *CallExpression) interface{} {
 		})
 	}
 
-	if len(arguments) != function.arity() {
-		panic(RuntimeError{
-			Token:   expr.Parenthesis, // Make sure this matches your struct field name
-			Message: fmt.Sprintf("Expected %d arguments but got %d.", function.arity(), len(arguments)),
-		})
+	if len(expr.Names) > 0 {
+		target := i.namedArgumentTarget(function)
+		if target == nil {
+			panic(RuntimeError{
+				Token:   expr.Names[0],
+				Message: "Named arguments need a function or class with an initializer.",
+			})
+		}
+
+		values := make([]interface{}, 0, len(expr.NamedArguments))
+		for _, arg := range expr.NamedArguments {
+			values = append(values, i.evaluate(arg))
+		}
+		arguments = target.bindArguments(arguments, expr.Names, values)
+		if _, max := function.arity(); max != -1 && len(arguments) > max {
+			i.checkArity(function, len(arguments), expr.Parenthesis)
+		}
+		target.checkArguments(arguments, expr.Parenthesis)
+	} else {
+		i.checkArity(function, len(arguments), expr.Parenthesis)
 	}
 
-	return function.call(i, arguments)
+	return function, arguments
+}
+
+// blameNativeError turns a NativeError, which natives raise without a token, into a
+// runtime error at token. It has to be deferred directly.
+func (i *Interpreter) blameNativeError(token Token) {
+	if r := recover(); r != nil {
+		if nativeErr, ok := r.(*NativeError); ok {
+			panic(RuntimeError{Token: token, Message: nativeErr.Message})
+		}
+		panic(r)
+	}
+}
+
+func (i *Interpreter) visitListExpr(expr *ListExpr) interface{} {
+	elements := make([]interface{}, 0, len(expr.Elements))
+	for _, element := range expr.Elements {
+		elements = append(elements, i.evaluate(element))
+	}
+	return NewLoxList(elements)
+}
+
+func (i *Interpreter) visitMapExpr(expr *MapExpr) interface{} {
+	result := NewLoxMap()
+	for index, key := range expr.Keys {
+		result.put(i.evaluate(key), i.evaluate(expr.Values[index]))
+	}
+	return result
+}
+
+func (i *Interpreter) visitIndexExpr(expr *IndexExpr) interface{} {
+	object := i.evaluate(expr.Object)
+	index := i.evaluate(expr.Index)
+	return i.indexGet(object, index, expr.Bracket)
+}
+
+func (i *Interpreter) visitIndexSetExpr(expr *IndexSetExpr) interface{} {
+	object := i.evaluate(expr.Object)
+	index := i.evaluate(expr.Index)
+	value := i.evaluate(expr.Value)
+	i.indexSet(object, index, value, expr.Bracket)
+	return value
+}
+
+// indexGet reads object[index]. Instances support it through __index__.
+func (i *Interpreter) indexGet(object interface{}, index interface{}, bracket Token) interface{} {
+	defer i.blameNativeError(bracket)
+
+	switch receiver := object.(type) {
+	case *LoxList:
+		return receiver.Elements[receiver.index(index)]
+	case *LoxMap:
+		value, exists := receiver.lookup(index)
+		if !exists {
+			panic(RuntimeError{
+				Token:   bracket,
+				Message: fmt.Sprintf("Undefined key '%s'.", i.stringify(index)),
+			})
+		}
+		return value
+	case string:
+		characters := []rune(receiver)
+		return string(characters[checkIndex(index, len(characters), "String")])
+	}
+
+	if method := findOperatorMethod(object, "__index__"); method != nil {
+		return method.call(i, []interface{}{index})
+	}
+	panic(RuntimeError{
+		Token:   bracket,
+		Message: "Only lists, maps, strings and instances with __index__ can be indexed.",
+	})
+}
+
+// indexSet assigns object[index]. Instances support it through __setindex__.
+func (i *Interpreter) indexSet(object interface{}, index interface{}, value interface{}, bracket Token) {
+	defer i.blameNativeError(bracket)
+
+	switch receiver := object.(type) {
+	case *LoxList:
+		receiver.Elements[receiver.index(index)] = value
+		return
+	case *LoxMap:
+		receiver.put(index, value)
+		return
+	}
+
+	if method := findOperatorMethod(object, "__setindex__"); method != nil {
+		method.call(i, []interface{}{index, value})
+		return
+	}
+	panic(RuntimeError{
+		Token:   bracket,
+		Message: "Only lists, maps and instances with __setindex__ support index assignment.",
+	})
+}
+
+// namedArgumentTarget returns the Lox function whose parameters named arguments refer to
+func (i *Interpreter) namedArgumentTarget(callee LoxCallable) *LoxFunction {
+	switch function := callee.(type) {
+	case *LoxFunction:
+		return function
+	case *LoxClass:
+		return function.findMethod("init")
+	}
+	return nil
+}
+
+func (i *Interpreter) checkArity(function LoxCallable, count int, paren Token) {
+	min, max := function.arity()
+	if count >= min && (max == -1 || count <= max) {
+		return
+	}
+
+	panic(RuntimeError{
+		Token:   paren,
+		Message: arityMessage(min, max, count),
+	})
+}
+
+// arityMessage describes a call with count arguments to a function taking min to max of
+// them, where a max of -1 means any number
+func arityMessage(min int, max int, count int) string {
+	switch {
+	case min == max:
+		return fmt.Sprintf("Expected %d arguments but got %d.", min, count)
+	case max == -1:
+		return fmt.Sprintf("Expected at least %d arguments but got %d.", min, count)
+	}
+	return fmt.Sprintf("Expected %d to %d arguments but got %d.", min, max, count)
 }
 
 func (i *Interpreter) visitGetExpr(expr *GetExpression) interface{} {
 	object := i.evaluate(expr.Object)
 
-	if instance, ok := object.(*LoxInstance); ok {
-		return instance.get(expr.Name)
+	if object == nil && expr.Optional {
+		panic(&optionalChainNil{})
+	}
+
+	if expr.Name.TokenType == PRIVATE_IDENTIFIER {
+		return i.privateReceiver(object, expr.Name).getPrivate(i.privateOwner(expr.Object), expr.Name)
+	}
+	return i.getProperty(object, expr.Name)
+}
+
+// privateReceiver checks that a private member is accessed on an instance. The resolver
+// already made sure objectExpr is `this`.
+func (i *Interpreter) privateReceiver(object interface{}, name Token) *LoxInstance {
+	instance, ok := object.(*LoxInstance)
+	if !ok {
+		panic(RuntimeError{
+			Token: name,
+			Message: "Only instances have private members.",
+		})
+	}
+	return instance
+}
+
+// privateOwner returns the class whose private members `this` in objectExpr can access
+func (i *Interpreter) privateOwner(objectExpr Expr) *LoxClass {
+	distance := i.locals[objectExpr]
+	return i.environment.getAt(distance, privateOwnerSlot).(*LoxClass)
+}
+
+// getProperty reads the property name of an evaluated object
+func (i *Interpreter) getProperty(object interface{}, name Token) interface{} {
+	switch receiver := object.(type) {
+	case *LoxInstance:
+		return receiver.get(i, name)
+	case *LoxClass:
+		return receiver.get(name)
+	case *LoxList:
+		return receiver.get(name)
+	case *LoxMap:
+		return receiver.get(name)
+	case *LoxGenerator:
+		return receiver.get(name)
+	case *LoxChannel:
+		return receiver.get(name)
+	case *LoxEnum:
+		return receiver.get(name)
+	case *LoxEnumCase:
+		return receiver.get(name)
 	}
 
 	panic(RuntimeError{
-		Token: expr.Name,
+		Token: name,
 		Message: "Only instances have properties.",
 	})
 }
 
+// setProperty assigns the property name of an evaluated object
+func (i *Interpreter) setProperty(object interface{}, name Token, value interface{}) {
+	switch receiver := object.(type) {
+	case *LoxInstance:
+		receiver.set(i, name, value)
+	case *LoxClass:
+		receiver.set(name, value)
+	default:
+		panic(RuntimeError{
+			Token: name,
+			Message: "Only instances have fields.",
+		})
+	}
+}
+
 func (i *Interpreter) visitSetExpr(expr *SetExpression) interface{} {
 	object := i.evaluate(expr.Object)
 
-	if _, ok := object.(*LoxInstance); !ok {
+	if expr.Name.TokenType == PRIVATE_IDENTIFIER {
+		instance := i.privateReceiver(object, expr.Name)
+		value := i.evaluate(expr.Value)
+		instance.setPrivate(i.privateOwner(expr.Object), expr.Name, value)
+		return value
+	}
+
+	switch object.(type) {
+	case *LoxInstance, *LoxClass:
+	default:
 		panic(RuntimeError{
 			Token: expr.Name,
 			Message: "Only instances have fields.",
@@ -213,7 +610,7 @@ func (i *Interpreter) visitSetExpr(expr *SetExpression) interface{} {
 	}
 	
 	value := i.evaluate(expr.Value)
-	object.(*LoxInstance).set(expr.Name, value)
+	i.setProperty(object, expr.Name, value)
 	return value 
 }
 
@@ -223,20 +620,65 @@ func (i *Interpreter) visitThisExpr(expr *ThisExpr) interface{} {
 
 func (i *Interpreter) visitSuperExpr(expr *SuperExpr) interface{} {
 	distance := i.locals[expr]
-	superclass := i.environment.getAt(distance, "super").(*LoxClass)
-	object := i.environment.getAt(distance - 1, "this").(*LoxInstance)
-	method := superclass.findMethod(expr.Method.Lexeme) 
+	superclass, ok := i.environment.getAt(distance, "super").(*LoxClass)
+	if !ok {
+		// Only trait methods mixed into a class without a superclass get here
+		panic(RuntimeError{
+			Token: expr.Keyword,
+			Message: "Can't use 'super' in a class with no superclass.",
+		})
+	}
+	object := i.environment.getAt(distance - 1, "this")
+
+	var method *LoxFunction
+	if _, static := object.(*LoxClass); static {
+		method = superclass.findStaticMethod(expr.Method.Lexeme)
+	} else {
+		method = superclass.findMethod(expr.Method.Lexeme)
+	}
 
 	if method == nil {
-		panic(&RuntimeError{
+		panic(RuntimeError{
 			Token: expr.Method,
 			Message: "Undefined property '" + expr.Method.Lexeme + "'.",
 		})
 	}
 
+	if method.Declaration.IsGetter {
+		return method.bind(object).call(i, []interface{}{})
+	}
 	return method.bind(object)
 }
 
+func (i *Interpreter) visitInterpolationExpr(expr *InterpolationExpr) interface{} {
+	var sb strings.Builder
+	for _, part := range expr.Parts {
+		sb.WriteString(i.stringify(i.evaluate(part)))
+	}
+	return sb.String()
+}
+
+func (i *Interpreter) visitConditionalExpr(expr *ConditionalExpr) interface{} {
+	if i.isTruthy(i.evaluate(expr.Condition)) {
+		return i.evaluate(expr.ThenBranch)
+	}
+	return i.evaluate(expr.ElseBranch)
+}
+
+func (i *Interpreter) visitOptionalChainExpr(expr *OptionalChainExpr) (result interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(*optionalChainNil); ok {
+				result = nil
+			} else {
+				panic(r)
+			}
+		}
+	}()
+
+	return i.evaluate(expr.Expression)
+}
+
 // ----------------------------------------------
 
 // Statement visitor function implementations
@@ -262,7 +704,11 @@ func (i *Interpreter) visitVarStmt(stmt *VarStatement) interface{} {
 		value = i.evaluate(stmt.Initializer)
 	}
 
-	i.environment.define(stmt.Name.Lexeme, value)
+	if stmt.IsConst {
+		i.environment.declareConstant(stmt.Name, value)
+	} else {
+		i.environment.declare(stmt.Name, value)
+	}
 
 	return nil
 }
@@ -290,16 +736,45 @@ func (i *Interpreter) visitWhileStmt(stmt *WhileStatement) interface{} {
 	return nil
 }
 
+func (i *Interpreter) visitForInStmt(stmt *ForInStatement) interface{} {
+	iterator := i.iterator(i.evaluate(stmt.Iterable), stmt.In)
+	for iterator.hasNext() {
+		// A new environment per iteration, so closures capture that iteration's value
+		environment := NewEnclosedEnvironment(i.environment)
+		environment.define(stmt.Name.Lexeme, iterator.next())
+		i.executeBlock([]Stmt{stmt.Body}, environment)
+	}
+	return nil
+}
+
+func (i *Interpreter) visitYieldStmt(stmt *YieldStatement) interface{} {
+	var value interface{}
+	if stmt.Value != nil {
+		value = i.evaluate(stmt.Value)
+	}
+	i.generator.yield(value)
+	return nil
+}
+
 func (i *Interpreter) visitFunctionStmt(stmt *FunctionStatement) interface{} {
 	function := NewLoxFunction(stmt, i.environment, false)
-	i.environment.define(stmt.Name.Lexeme, function)
+	i.environment.declare(stmt.Name, function)
 	return nil
 }
 
 func (i *Interpreter) visitReturnStmt(stmt *ReturnStatement) interface{} {
 	var value interface{} = nil
 
-	if stmt.Value != nil {
+	if call, ok := stmt.Value.(*CallExpression); ok && !i.noTailCalls {
+		// A Lox function called in tail position runs once this frame has returned
+		function, arguments := i.evaluateCall(call)
+		if tail, ok := function.(*LoxFunction); ok {
+			panic(&ReturnValue{
+				TailCall: &tailCall{function: tail, arguments: arguments},
+			})
+		}
+		value = i.callFunction(function, arguments, call.Parenthesis)
+	} else if stmt.Value != nil {
 		value = i.evaluate(stmt.Value)
 	}
 
@@ -322,7 +797,19 @@ func (i *Interpreter) visitClassStmt(stmt *ClassStatement) interface{} {
         }
     }
 
-    i.environment.define(stmt.Name.Lexeme, nil)
+    traits := make([]*LoxTrait, 0, len(stmt.Traits))
+    for _, expr := range stmt.Traits {
+        trait, ok := i.evaluate(expr).(*LoxTrait)
+        if !ok {
+            panic(RuntimeError{
+                Token:   expr.Name,
+                Message: fmt.Sprintf("'%s' is not a trait.", expr.Name.Lexeme),
+            })
+        }
+        traits = append(traits, trait)
+    }
+
+    i.environment.declare(stmt.Name, nil)
 
     enclosingEnv := i.environment
     if stmt.Superclass != nil {
@@ -336,13 +823,104 @@ func (i *Interpreter) visitClassStmt(stmt *ClassStatement) interface{} {
         methods[method.Name.Lexeme] = function
     }
 
-    klass := NewLoxClass(stmt.Name.Lexeme, superclass, methods)
+    staticMethods := make(map[string]*LoxFunction)
+    for _, method := range stmt.StaticMethods {
+        staticMethods[method.Name.Lexeme] = NewLoxFunction(method, i.environment, false)
+    }
+
+    setters := make(map[string]*LoxFunction)
+    for _, setter := range stmt.Setters {
+        setters[setter.Name.Lexeme] = NewLoxFunction(setter, i.environment, false)
+    }
+
+    i.mixInTraits(stmt, traits, superclass, methods, setters)
+
+    privateMethods := make(map[string]*LoxFunction)
+    for _, method := range stmt.PrivateMethods {
+        privateMethods[method.Name.Lexeme] = NewLoxFunction(method, i.environment, false)
+    }
+
+    klass := NewLoxClass(stmt.Name.Lexeme, superclass, methods, staticMethods, setters)
+    klass.PrivateMethods = privateMethods
+    klass.InstanceFields = stmt.Fields
+    klass.PrivateFields = stmt.PrivateFields
+    klass.Closure = i.environment
+    for _, functions := range []map[string]*LoxFunction{methods, staticMethods, setters, privateMethods} {
+        for _, function := range functions {
+            function.Owner = klass
+        }
+    }
+    classEnv := i.environment
 
     if stmt.Superclass != nil {
         i.environment = enclosingEnv
     }
 
     i.environment.assign(stmt.Name, klass)
+
+    // Static fields are initialized in order once the class exists, with `this` bound to it
+    staticEnv := NewEnclosedEnvironment(classEnv)
+    staticEnv.define("this", klass)
+    staticEnv.define(privateOwnerSlot, klass)
+    for _, field := range stmt.StaticFields {
+        var value interface{}
+        if field.Initializer != nil {
+            value = i.evaluateIn(field.Initializer, staticEnv)
+        }
+        klass.Fields[field.Name.Lexeme] = value
+    }
+    return nil
+}
+
+// mixInTraits copies the methods and setters of traits into those of a class. The class's
+// own members take precedence, but two traits providing the same one is an error.
+func (i *Interpreter) mixInTraits(stmt *ClassStatement, traits []*LoxTrait, superclass *LoxClass, methods map[string]*LoxFunction, setters map[string]*LoxFunction) {
+    methodProviders := make(map[string]*LoxTrait)
+    setterProviders := make(map[string]*LoxTrait)
+
+    mixIn := func(with Token, trait *LoxTrait, kind string, function *LoxFunction, members map[string]*LoxFunction, providers map[string]*LoxTrait) {
+        name := function.Declaration.Name.Lexeme
+        if _, exists := members[name]; exists {
+            provider, mixed := providers[name]
+            if !mixed {
+                return
+            }
+            if provider != trait {
+                panic(RuntimeError{
+                    Token:   with,
+                    Message: fmt.Sprintf("%s '%s' is provided by both %s and %s.", kind, name, provider.Name, trait.Name),
+                })
+            }
+        }
+        providers[name] = trait
+        members[name] = function
+    }
+
+    for index, trait := range traits {
+        environment := trait.environment(superclass)
+        with := stmt.Traits[index].Name
+        for _, method := range trait.Methods {
+            function := NewLoxFunction(method, environment, method.Name.Lexeme == "init")
+            mixIn(with, trait, "Method", function, methods, methodProviders)
+        }
+        for _, setter := range trait.Setters {
+            mixIn(with, trait, "Setter", NewLoxFunction(setter, environment, false), setters, setterProviders)
+        }
+    }
+}
+
+func (i *Interpreter) visitEnumStmt(stmt *EnumStatement) interface{} {
+	cases := make([]string, len(stmt.Cases))
+	for index, enumCase := range stmt.Cases {
+		cases[index] = enumCase.Lexeme
+	}
+	i.environment.declare(stmt.Name, NewLoxEnum(stmt.Name.Lexeme, cases))
+	return nil
+}
+
+func (i *Interpreter) visitTraitStmt(stmt *TraitStatement) interface{} {
+    trait := NewLoxTrait(stmt.Name.Lexeme, stmt.Methods, stmt.Setters, i.environment)
+    i.environment.declare(stmt.Name, trait)
     return nil
 }
 
@@ -362,10 +940,7 @@ func (i *Interpreter) executeBlock(statements []Stmt, environment *Environment)
 }
 
 func (i *Interpreter) checkNumberOperands(leftOperand interface{}, operator Token, rightOperand interface{}) {
-	_, leftOk := leftOperand.(float64)
-	_, rightOk := rightOperand.(float64)
-
-	if leftOk && rightOk {
+	if isNumber(leftOperand) && isNumber(rightOperand) {
 		return
 	}
 
@@ -373,18 +948,61 @@ func (i *Interpreter) checkNumberOperands(leftOperand interface{}, operator Toke
 
 }
 
+// compareOperands orders the operands of a comparison, which are two numbers or two
+// cases of the same enum
+func (i *Interpreter) compareOperands(left interface{}, operator Token, right interface{}) (int, bool) {
+	if leftCase, ok := left.(*LoxEnumCase); ok {
+		if rightCase, ok := right.(*LoxEnumCase); ok && leftCase.Enum == rightCase.Enum {
+			return compareNumbers(leftCase.Ordinal, rightCase.Ordinal)
+		}
+		panic(RuntimeError{Token: operator, Message: "Can only compare cases of the same enum."})
+	}
+	i.checkNumberOperands(left, operator, right)
+	return compareNumbers(left, right)
+}
+
 func (i *Interpreter) checkOperand(operator Token, operand interface{}) {
-	_, ok := operand.(float64)
-	if ok {
+	if isNumber(operand) {
 		return
 	}
 	panic(RuntimeError{Token: operator, Message: "Operand must be a number."})
 }
 
+// checkIntegerOperands returns both operands as integers, failing unless they are integral numbers
+func (i *Interpreter) checkIntegerOperands(leftOperand interface{}, operator Token, rightOperand interface{}) (*big.Int, *big.Int) {
+	leftInt, leftOk := toIntegral(leftOperand)
+	rightInt, rightOk := toIntegral(rightOperand)
+
+	if leftOk && rightOk {
+		return leftInt, rightInt
+	}
+
+	panic(RuntimeError{Token: operator, Message: "Operands must be integers."})
+}
+
+func (i *Interpreter) checkIntegerOperand(operator Token, operand interface{}) *big.Int {
+	if value, ok := toIntegral(operand); ok {
+		return value
+	}
+	panic(RuntimeError{Token: operator, Message: "Operand must be an integer."})
+}
+
 func (i *Interpreter) evaluate(expr Expr) interface{} {
 	return expr.Accept(i)
 }
 
+// evaluateIn evaluates expr with environment as the current environment
+func (i *Interpreter) evaluateIn(expr Expr, environment *Environment) interface{} {
+	previousEnvironment := i.environment
+	i.environment = environment
+
+	defer func() {
+		i.environment = previousEnvironment
+	}()
+
+	return i.evaluate(expr)
+}
+
 func (i *Interpreter) isTruthy(object interface{}) bool {
 	if object == nil {
 		return false
@@ -398,16 +1016,36 @@ func (i *Interpreter) isTruthy(object interface{}) bool {
 }
 
 func (i *Interpreter) isEqual(left interface{}, right interface{}) bool {
+	if method := findOperatorMethod(left, "__eq__"); method != nil {
+		return i.isTruthy(method.call(i, []interface{}{right}))
+	}
 	if left == nil && right == nil {
 		return true
 	}
 	if left == nil {
 		return false
 	}
+	if isNumber(left) && isNumber(right) {
+		return numbersEqual(left, right)
+	}
+	switch left.(type) {
+	case *LoxInstance, *LoxEnumCase:
+		// Instances without __eq__ and enum cases are only equal to themselves
+		return left == right
+	}
 
 	return reflect.DeepEqual(left, right)
 }
 
+func (i *Interpreter) assignVariable(name Token, expr Expr, value interface{}) {
+	distance, exists := i.locals[expr]
+	if exists {
+		i.environment.assignAt(distance, name, value)
+	} else {
+		i.globals.assign(name, value)
+	}
+}
+
 func (i *Interpreter) lookUpVariable(name Token, expr Expr) interface{} {
 	distance, exists := i.locals[expr]
 	if exists {
@@ -427,7 +1065,27 @@ func (e RuntimeError) Error() string {
 	return e.Message + " at " + e.Token.Lexeme
 }
 
+// NativeError is raised by native functions, which have no token to report against
+type NativeError struct {
+	Message string
+}
+
+func (e *NativeError) Error() string {
+	return e.Message
+}
+
+// handleRuntimeError reports err. The text form has no trailing newline, so when failed
+// tasks and promises add more errors at the end, each starts on a line of its own.
 func handleRuntimeError(err RuntimeError) {
-	fmt.Fprintf(os.Stderr, "%s\n[line %d]", err.Message, err.Token.Line)
+	separator := ""
+	if hasRuntimeError {
+		separator = "\n"
+	}
+	reporter.add(Diagnostic{
+		Severity: SEVERITY_ERROR,
+		Code:     CODE_RUNTIME_ERROR,
+		Location: Location{Line: err.Token.Line, Column: err.Token.Column},
+		Message:  err.Message,
+	}, fmt.Sprintf("%s%s\n[line %d]", separator, err.Message, err.Token.Line))
 	hasRuntimeError = true
 }
@@ -1,6 +1,7 @@
 package main 
 
 type LoxCallable interface {
-	arity() int 
+	// arity returns the minimum and maximum number of arguments, max is -1 when variadic
+	arity() (int, int)
 	call(interpreter *Interpreter, arguments []interface{}) interface{}
 }
\ No newline at end of file
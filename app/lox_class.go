@@ -1,29 +1,50 @@
 package main
 
+import "fmt"
+
 type LoxClass struct {
 	Name    string
 	Methods map[string]*LoxFunction
 	Superclass *LoxClass
+	// StaticMethods and Fields belong to the class itself. Both are inherited by subclasses.
+	StaticMethods map[string]*LoxFunction
+	Fields        map[string]interface{}
+	Setters       map[string]*LoxFunction
+	// PrivateMethods and PrivateFields are the class's own `#name` members. The initializers
+	// of InstanceFields and PrivateFields run against every new instance, with Closure
+	// around them.
+	PrivateMethods map[string]*LoxFunction
+	InstanceFields []*VarStatement
+	PrivateFields  []*VarStatement
+	Closure        *Environment
 }
 
-func NewLoxClass(name string, superclass *LoxClass, methods map[string]*LoxFunction) *LoxClass {
+// privateOwnerSlot is the hidden variable, next to `this`, holding the class whose
+// private members the running method may access
+const privateOwnerSlot = "#owner"
+
+func NewLoxClass(name string, superclass *LoxClass, methods map[string]*LoxFunction, staticMethods map[string]*LoxFunction, setters map[string]*LoxFunction) *LoxClass {
 	return &LoxClass{
 		Name:    name,
 		Methods: methods,
 		Superclass: superclass,
+		StaticMethods: staticMethods,
+		Fields:        make(map[string]interface{}),
+		Setters:       setters,
 	}
 }
 
-func (l *LoxClass) arity() int {
+func (l *LoxClass) arity() (int, int) {
 	initializer := l.findMethod("init")
 	if initializer == nil {
-		return 0 
+		return 0, 0
 	}
 	return initializer.arity()
 }
 
 func (l *LoxClass) call(interpreter *Interpreter, arguments []interface{}) interface{} {
 	instance := NewLoxInstance(l)
+	l.initializeFields(interpreter, instance)
 
 	initializer := l.findMethod("init") 
 
@@ -34,6 +55,48 @@ func (l *LoxClass) call(interpreter *Interpreter, arguments []interface{}) inter
 	return instance
 }
 
+// initializeFields gives instance the declared fields of l and its superclasses,
+// superclasses first
+func (l *LoxClass) initializeFields(interpreter *Interpreter, instance *LoxInstance) {
+	if l.Superclass != nil {
+		l.Superclass.initializeFields(interpreter, instance)
+	}
+	if len(l.InstanceFields) == 0 && len(l.PrivateFields) == 0 {
+		return
+	}
+
+	environment := NewEnclosedEnvironment(l.Closure)
+	environment.define("this", instance)
+	environment.define(privateOwnerSlot, l)
+
+	for _, field := range l.InstanceFields {
+		var value interface{}
+		if field.Initializer != nil {
+			value = interpreter.evaluateIn(field.Initializer, environment)
+		}
+		instance.Fields[field.Name.Lexeme] = value
+	}
+
+	fields := instance.privateFields(l)
+	for _, field := range l.PrivateFields {
+		var value interface{}
+		if field.Initializer != nil {
+			value = interpreter.evaluateIn(field.Initializer, environment)
+		}
+		fields[field.Name.Lexeme] = value
+	}
+}
+
+// isSubclassOf reports whether l is other or inherits from it
+func (l *LoxClass) isSubclassOf(other *LoxClass) bool {
+	for class := l; class != nil; class = class.Superclass {
+		if class == other {
+			return true
+		}
+	}
+	return false
+}
+
 func (l *LoxClass) findMethod(name string) *LoxFunction {
     if method, ok := l.Methods[name]; ok {
         return method
@@ -46,6 +109,48 @@ func (l *LoxClass) findMethod(name string) *LoxFunction {
     return nil
 }
 
+// get looks up a static field or method, walking up the superclasses. Static methods
+// are bound to l so `this` is the class they were accessed on.
+func (l *LoxClass) get(name Token) interface{} {
+	for klass := l; klass != nil; klass = klass.Superclass {
+		if value, exists := klass.Fields[name.Lexeme]; exists {
+			return value
+		}
+	}
+
+	if method := l.findStaticMethod(name.Lexeme); method != nil {
+		return method.bind(l)
+	}
+
+	panic(RuntimeError{
+		Token: name,
+		Message: fmt.Sprintf("Undefined property %s.", name.Lexeme),
+	})
+}
+
+// set assigns a static field of l, shadowing any inherited one
+func (l *LoxClass) set(name Token, value interface{}) {
+	l.Fields[name.Lexeme] = value
+}
+
+func (l *LoxClass) findSetter(name string) *LoxFunction {
+	for klass := l; klass != nil; klass = klass.Superclass {
+		if setter, ok := klass.Setters[name]; ok {
+			return setter
+		}
+	}
+	return nil
+}
+
+func (l *LoxClass) findStaticMethod(name string) *LoxFunction {
+	for klass := l; klass != nil; klass = klass.Superclass {
+		if method, ok := klass.StaticMethods[name]; ok {
+			return method
+		}
+	}
+	return nil
+}
+
 func (l *LoxClass) String() string {
 	return l.Name
 }
\ No newline at end of file
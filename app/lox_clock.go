@@ -4,11 +4,15 @@ import "time"
 
 type LoxClock struct {}
 
-func(l *LoxClock) arity() int {
-	return 0
+func(l *LoxClock) arity() (int, int) {
+	return 0, 0
 }
 
+// call returns the wall clock time, or the event loop's time when its clock is fake
 func(l *LoxClock) call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	if interpreter.loop.fake {
+		return interpreter.loop.now.Seconds()
+	}
 	return float64(time.Now().UnixNano()) / 1e9
 }
 
@@ -19,10 +23,18 @@ func (l *LoxClock) String() string {
 func NewInterpreter(shouldPrintExpressions bool) *Interpreter {
 	globals := NewEnvironment()
 	globals.define("clock", &LoxClock{})
+	globals.define("format", &LoxFormat{})
+	globals.define("Channel", NewChannelNative())
+	globals.define("setTimeout", NewTimerNative("setTimeout", false))
+	globals.define("setInterval", NewTimerNative("setInterval", true))
+	globals.define("clearTimeout", NewClearTimerNative("clearTimeout"))
+	globals.define("clearInterval", NewClearTimerNative("clearInterval"))
+	globals.define("sleep", NewSleepNative())
 	return &Interpreter{
 		shouldPrintExpressions: shouldPrintExpressions,
 		globals: globals,
 		environment: globals,
 		locals: make(map[Expr]int),
+		loop: NewEventLoop(),
 	}
 }
\ No newline at end of file
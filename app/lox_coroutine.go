@@ -0,0 +1,99 @@
+package main
+
+// coroutine runs a function body on its own goroutine and interpreter, but never at the
+// same time as whoever resumes it: control is handed back and forth over channels. It
+// backs generators, which suspend at `yield`, and async functions, which suspend at
+// `await`. A coroutine that is dropped before finishing leaves its goroutine parked.
+type coroutine struct {
+	interpreter *Interpreter
+	body        func(interpreter *Interpreter) interface{}
+
+	started bool
+	done    bool
+
+	resumes  chan resumption
+	suspends chan suspension
+}
+
+// resumption is what a suspended coroutine is resumed with: a value, or an error to
+// raise at the point where it suspended
+type resumption struct {
+	value interface{}
+	err   interface{}
+}
+
+// suspension is what a coroutine hands back when it suspends or finishes: a value, the
+// body's result when done is set, or the error the body failed with
+type suspension struct {
+	value interface{}
+	done  bool
+	err   interface{}
+}
+
+// newCoroutine prepares a coroutine running body on a fork of interpreter. The body
+// starts on the first resume.
+func newCoroutine(interpreter *Interpreter, body func(interpreter *Interpreter) interface{}) *coroutine {
+	return &coroutine{
+		interpreter: interpreter.fork(),
+		body:        body,
+		resumes:     make(chan resumption),
+		suspends:    make(chan suspension),
+	}
+}
+
+func (c *coroutine) run() {
+	<-c.resumes
+
+	result := suspension{done: true}
+	defer func() {
+		if r := recover(); r != nil {
+			result.err = r
+		}
+		c.suspends <- result
+	}()
+
+	result.value = c.body(c.interpreter)
+}
+
+// resume runs the coroutine until it suspends or finishes
+func (c *coroutine) resume(with resumption) suspension {
+	if !c.started {
+		c.started = true
+		go c.run()
+	}
+
+	c.resumes <- with
+	result := <-c.suspends
+	if result.done {
+		c.done = true
+	}
+	return result
+}
+
+// suspend hands value to whoever resumed the coroutine and waits to be resumed again.
+// It runs on the coroutine's goroutine and raises the error it is resumed with, if any.
+func (c *coroutine) suspend(value interface{}) interface{} {
+	c.suspends <- suspension{value: value}
+	with := <-c.resumes
+	if with.err != nil {
+		panic(with.err)
+	}
+	return with.value
+}
+
+// runFunctionBody executes the body of function in environment, returning the value of
+// its `return` statement
+func runFunctionBody(interpreter *Interpreter, function *LoxFunction, environment *Environment) (result interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			if returnValue, ok := r.(*ReturnValue); ok {
+				result = returnValue.result(interpreter)
+				return
+			}
+			panic(r)
+		}
+	}()
+
+	interpreter.executeBlock(function.Declaration.Body, environment)
+	return nil
+}
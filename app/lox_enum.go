@@ -0,0 +1,78 @@
+package main
+
+import "fmt"
+
+// LoxEnum is the value of an `enum` declaration. Its cases are its properties.
+type LoxEnum struct {
+	Name  string
+	Cases []*LoxEnumCase
+}
+
+// LoxEnumCase is one case of an enum. Cases are only equal to themselves and are
+// ordered by their position in the declaration.
+type LoxEnumCase struct {
+	Enum    *LoxEnum
+	Name    string
+	Ordinal int64
+}
+
+func NewLoxEnum(name string, cases []string) *LoxEnum {
+	enum := &LoxEnum{Name: name}
+	for ordinal, caseName := range cases {
+		enum.Cases = append(enum.Cases, &LoxEnumCase{
+			Enum:    enum,
+			Name:    caseName,
+			Ordinal: int64(ordinal),
+		})
+	}
+	return enum
+}
+
+func (e *LoxEnum) get(name Token) interface{} {
+	for _, enumCase := range e.Cases {
+		if enumCase.Name == name.Lexeme {
+			return enumCase
+		}
+	}
+	if name.Lexeme == "values" {
+		return NewNativeFunction("values", 0, func(interpreter *Interpreter, arguments []interface{}) interface{} {
+			return NewLoxList(e.values())
+		})
+	}
+
+	panic(RuntimeError{
+		Token:   name,
+		Message: fmt.Sprintf("Undefined case %s.%s.", e.Name, name.Lexeme),
+	})
+}
+
+// values returns the cases in declaration order
+func (e *LoxEnum) values() []interface{} {
+	values := make([]interface{}, len(e.Cases))
+	for index, enumCase := range e.Cases {
+		values[index] = enumCase
+	}
+	return values
+}
+
+func (e *LoxEnum) String() string {
+	return e.Name
+}
+
+func (c *LoxEnumCase) get(name Token) interface{} {
+	switch name.Lexeme {
+	case "name":
+		return c.Name
+	case "ordinal":
+		return c.Ordinal
+	}
+
+	panic(RuntimeError{
+		Token:   name,
+		Message: fmt.Sprintf("Undefined property %s.", name.Lexeme),
+	})
+}
+
+func (c *LoxEnumCase) String() string {
+	return c.Enum.Name + "." + c.Name
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// LoxFormat is the format(number, spec) native. spec follows a small subset of Python's
+// format mini-language: [+][0][width][,][.precision][type] where type is one of
+// f, e, g, % for floats and d, x, X, o, b for integers.
+type LoxFormat struct{}
+
+func (l *LoxFormat) arity() (int, int) {
+	return 2, 2
+}
+
+func (l *LoxFormat) call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	if !isNumber(arguments[0]) {
+		panic(&NativeError{Message: "format() expects a number as its first argument."})
+	}
+	spec, ok := arguments[1].(string)
+	if !ok {
+		panic(&NativeError{Message: "format() expects a string spec as its second argument."})
+	}
+
+	formatted, problem := formatNumberSpec(arguments[0], spec)
+	if problem != "" {
+		panic(&NativeError{Message: problem})
+	}
+	return formatted
+}
+
+func (l *LoxFormat) String() string {
+	return "<native fn>"
+}
+
+type numberSpec struct {
+	sign      bool
+	zeroPad   bool
+	width     int
+	grouping  bool
+	precision int
+	verb      byte
+}
+
+// parseNumberSpec parses a format spec, returning a description of the problem if it is invalid
+func parseNumberSpec(spec string) (numberSpec, string) {
+	parsed := numberSpec{precision: -1}
+	rest := spec
+
+	if strings.HasPrefix(rest, "+") {
+		parsed.sign = true
+		rest = rest[1:]
+	}
+	if strings.HasPrefix(rest, "0") {
+		parsed.zeroPad = true
+		rest = rest[1:]
+	}
+
+	digits := 0
+	for digits < len(rest) && rest[digits] >= '0' && rest[digits] <= '9' {
+		digits++
+	}
+	if digits > 0 {
+		parsed.width, _ = strconv.Atoi(rest[:digits])
+		rest = rest[digits:]
+	}
+
+	if strings.HasPrefix(rest, ",") {
+		parsed.grouping = true
+		rest = rest[1:]
+	}
+
+	if strings.HasPrefix(rest, ".") {
+		digits = 1
+		for digits < len(rest) && rest[digits] >= '0' && rest[digits] <= '9' {
+			digits++
+		}
+		if digits == 1 {
+			return parsed, fmt.Sprintf("Invalid format spec '%s': expected precision after '.'.", spec)
+		}
+		parsed.precision, _ = strconv.Atoi(rest[1:digits])
+		rest = rest[digits:]
+	}
+
+	if len(rest) > 1 || (len(rest) == 1 && !strings.Contains("fegdxXob%", rest)) {
+		return parsed, fmt.Sprintf("Invalid format spec '%s'.", spec)
+	}
+	if len(rest) == 1 {
+		parsed.verb = rest[0]
+	}
+	return parsed, ""
+}
+
+// formatNumberSpec formats a Lox number according to a format spec, returning a
+// description of the problem if that isn't possible
+func formatNumberSpec(value interface{}, spec string) (string, string) {
+	parsed, problem := parseNumberSpec(spec)
+	if problem != "" {
+		return "", problem
+	}
+
+	var digits string
+	switch parsed.verb {
+	case 'd', 'x', 'X', 'o', 'b':
+		integer, ok := toIntegral(value)
+		if !ok {
+			return "", fmt.Sprintf("Format spec '%s' requires an integer.", spec)
+		}
+		bases := map[byte]int{'d': 10, 'x': 16, 'X': 16, 'o': 8, 'b': 2}
+		digits = integer.Text(bases[parsed.verb])
+		if parsed.verb == 'X' {
+			digits = strings.ToUpper(digits)
+		}
+	case 'f', 'e', 'g':
+		digits = formatFloatSpec(toFloat(value), parsed.verb, parsed.precision)
+	case '%':
+		digits = formatFloatSpec(toFloat(value)*100, 'f', parsed.precision) + "%"
+	default:
+		if parsed.precision >= 0 {
+			digits = formatFloatSpec(toFloat(value), 'g', parsed.precision)
+		} else {
+			digits = formatNumber(value)
+		}
+	}
+
+	negative := strings.HasPrefix(digits, "-")
+	digits = strings.TrimPrefix(digits, "-")
+	if parsed.grouping {
+		digits = groupThousands(digits)
+	}
+
+	sign := ""
+	if negative {
+		sign = "-"
+	} else if parsed.sign {
+		sign = "+"
+	}
+
+	if padding := parsed.width - len(sign) - len(digits); padding > 0 {
+		if parsed.zeroPad {
+			digits = strings.Repeat("0", padding) + digits
+		} else {
+			sign = strings.Repeat(" ", padding) + sign
+		}
+	}
+	return sign + digits, ""
+}
+
+func formatFloatSpec(value float64, verb byte, precision int) string {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return formatNumber(value)
+	}
+	if precision < 0 && verb != 'g' {
+		precision = 6
+	}
+	return strconv.FormatFloat(value, verb, precision, 64)
+}
+
+// groupThousands inserts ',' between groups of three digits of the integer part
+func groupThousands(digits string) string {
+	integer, fraction := digits, ""
+	if index := strings.IndexAny(digits, ".e%"); index >= 0 {
+		integer, fraction = digits[:index], digits[index:]
+	}
+
+	var sb strings.Builder
+	for index, digit := range integer {
+		if index > 0 && (len(integer)-index)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(digit)
+	}
+	return sb.String() + fraction
+}
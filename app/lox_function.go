@@ -2,10 +2,20 @@ package main
 
 import "fmt"
 
+// missingArgument fills the slot of a parameter that gets its default value
+var missingArgument interface{} = &missingArgumentMarker{}
+
+// missingArgumentMarker isn't zero-sized so its address can't coincide with a Lox value's
+type missingArgumentMarker struct {
+	_ byte
+}
+
 type LoxFunction struct {
 	Declaration *FunctionStatement
 	Closure     *Environment
 	IsInitializer bool
+	// Owner is the class that declared the method, whose private members it can reach
+	Owner *LoxClass
 }
 
 func NewLoxFunction(declaration *FunctionStatement, closure *Environment, isInitializer bool) *LoxFunction {
@@ -16,11 +26,46 @@ func NewLoxFunction(declaration *FunctionStatement, closure *Environment, isInit
 	}
 }
 
-func (l *LoxFunction) call(interpreter *Interpreter, arguments []interface{}) (result interface{}) {
+// call runs the function. Calls the body makes in tail position come back as the next
+// function to run, so tail recursion loops here instead of growing the stack.
+func (l *LoxFunction) call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	function := l
+	for {
+		result, next := function.callFrame(interpreter, arguments)
+		if next == nil {
+			return result
+		}
+		function, arguments = next.function, next.arguments
+	}
+}
+
+// callFrame runs the function's body once, returning either its result or the tail
+// call it ended with
+func (l *LoxFunction) callFrame(interpreter *Interpreter, arguments []interface{}) (result interface{}, next *tailCall) {
 	environment := NewEnclosedEnvironment(l.Closure)
 
 	for index, param := range l.Declaration.Params {
-		environment.define(param.Lexeme, arguments[index])
+		if index < len(arguments) && arguments[index] != missingArgument {
+			environment.define(param.Lexeme, arguments[index])
+		} else {
+			// Defaults are evaluated on every call and can refer to earlier parameters
+			environment.define(param.Lexeme, interpreter.evaluateIn(l.Declaration.Defaults[index], environment))
+		}
+	}
+
+	if l.Declaration.Rest != nil {
+		rest := make([]interface{}, 0)
+		if len(arguments) > len(l.Declaration.Params) {
+			rest = append(rest, arguments[len(l.Declaration.Params):]...)
+		}
+		environment.define(l.Declaration.Rest.Lexeme, NewLoxList(rest))
+	}
+
+	if l.Declaration.IsGenerator {
+		return NewLoxGenerator(interpreter, l, environment), nil
+	}
+	if l.Declaration.IsAsync {
+		return callAsync(interpreter, l, environment), nil
 	}
 
 	defer func() {
@@ -29,7 +74,7 @@ func (l *LoxFunction) call(interpreter *Interpreter, arguments []interface{}) (r
                 if l.IsInitializer {
                     result = l.Closure.getAt(0, "this")
                 } else {
-                    result = returnVal.Value
+                    result, next = returnVal.Value, returnVal.TailCall
                 }
 			} else {
 				panic(r)
@@ -39,21 +84,87 @@ func (l *LoxFunction) call(interpreter *Interpreter, arguments []interface{}) (r
 
 	interpreter.executeBlock(l.Declaration.Body, environment)
 	if (l.IsInitializer) {
-		return l.Closure.getAt(0, "this")
+		return l.Closure.getAt(0, "this"), nil
+	}
+	return nil, nil
+}
+
+func (l *LoxFunction) arity() (int, int) {
+	required := 0
+	for _, defaultValue := range l.Declaration.Defaults {
+		if defaultValue == nil {
+			required++
+		}
+	}
+	if l.Declaration.Rest != nil {
+		return required, -1
 	}
-	return nil
+	return required, len(l.Declaration.Params)
 }
 
-func (l *LoxFunction) arity() int {
-	return len(l.Declaration.Params)
+// bindArguments places named arguments in their parameter's slot after the positional
+// ones. Slots left empty hold missingArgument.
+func (l *LoxFunction) bindArguments(positional []interface{}, names []Token, values []interface{}) []interface{} {
+	params := l.Declaration.Params
+	arguments := make([]interface{}, len(params))
+	for index := range arguments {
+		arguments[index] = missingArgument
+	}
+	copy(arguments, positional)
+	if len(positional) > len(params) {
+		arguments = append(arguments, positional[len(params):]...)
+	}
+
+	for index, name := range names {
+		slot := -1
+		for paramIndex, param := range params {
+			if param.Lexeme == name.Lexeme {
+				slot = paramIndex
+			}
+		}
+		if slot == -1 {
+			panic(RuntimeError{
+				Token:   name,
+				Message: fmt.Sprintf("Unknown parameter '%s'.", name.Lexeme),
+			})
+		}
+		if arguments[slot] != missingArgument {
+			panic(RuntimeError{
+				Token:   name,
+				Message: fmt.Sprintf("Argument '%s' given more than once.", name.Lexeme),
+			})
+		}
+		arguments[slot] = values[index]
+	}
+
+	return arguments
+}
+
+// checkArguments reports required parameters that received no argument
+func (l *LoxFunction) checkArguments(arguments []interface{}, paren Token) {
+	for index, param := range l.Declaration.Params {
+		if l.Declaration.Defaults[index] == nil && (index >= len(arguments) || arguments[index] == missingArgument) {
+			panic(RuntimeError{
+				Token:   paren,
+				Message: fmt.Sprintf("Missing argument for parameter '%s'.", param.Lexeme),
+			})
+		}
+	}
 }
 
 func (l *LoxFunction) String() string {
 	return fmt.Sprintf("<fn %s>", l.Declaration.Name.Lexeme)
 }
 
-func (l *LoxFunction) bind(instance *LoxInstance) *LoxFunction {
+// bind returns a copy of the function with `this` set to receiver, an instance, or a
+// class for static methods
+func (l *LoxFunction) bind(receiver interface{}) *LoxFunction {
 	environment := NewEnclosedEnvironment(l.Closure)
-	environment.define("this", instance)
-	return NewLoxFunction(l.Declaration, environment, l.IsInitializer)
+	environment.define("this", receiver)
+	if l.Owner != nil {
+		environment.define(privateOwnerSlot, l.Owner)
+	}
+	bound := NewLoxFunction(l.Declaration, environment, l.IsInitializer)
+	bound.Owner = l.Owner
+	return bound
 }
\ No newline at end of file
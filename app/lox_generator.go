@@ -0,0 +1,93 @@
+package main
+
+import "fmt"
+
+// LoxGenerator is what calling a function containing `yield` returns. Its body runs as
+// a coroutine, up to the next `yield` whenever a value is asked for.
+type LoxGenerator struct {
+	function  *LoxFunction
+	coroutine *coroutine
+
+	done bool
+	// buffered is set when value holds a yielded value hasNext looked ahead at
+	buffered bool
+	value    interface{}
+}
+
+func NewLoxGenerator(interpreter *Interpreter, function *LoxFunction, environment *Environment) *LoxGenerator {
+	generator := &LoxGenerator{
+		function: function,
+	}
+	generator.coroutine = newCoroutine(interpreter, func(interpreter *Interpreter) interface{} {
+		return runFunctionBody(interpreter, function, environment)
+	})
+	generator.coroutine.interpreter.generator = generator
+	return generator
+}
+
+// yield hands value to the caller and blocks until the next value is asked for. It runs
+// on the generator's goroutine.
+func (g *LoxGenerator) yield(value interface{}) {
+	g.coroutine.suspend(value)
+}
+
+// advance runs the body up to its next yield
+func (g *LoxGenerator) advance() {
+	if g.buffered || g.done {
+		return
+	}
+
+	result := g.coroutine.resume(resumption{})
+	if result.err != nil {
+		g.done = true
+		panic(result.err)
+	}
+	if result.done {
+		g.done = true
+		return
+	}
+	g.buffered = true
+	g.value = result.value
+}
+
+func (g *LoxGenerator) hasNext() bool {
+	g.advance()
+	return !g.done
+}
+
+func (g *LoxGenerator) next() interface{} {
+	g.advance()
+	if g.done {
+		panic(&NativeError{Message: "Generator is exhausted."})
+	}
+	g.buffered = false
+	value := g.value
+	g.value = nil
+	return value
+}
+
+func (g *LoxGenerator) get(name Token) interface{} {
+	switch name.Lexeme {
+	case "hasNext":
+		return NewNativeFunction("hasNext", 0, func(interpreter *Interpreter, arguments []interface{}) interface{} {
+			return g.hasNext()
+		})
+	case "next":
+		return NewNativeFunction("next", 0, func(interpreter *Interpreter, arguments []interface{}) interface{} {
+			return g.next()
+		})
+	case "iterator":
+		return NewNativeFunction("iterator", 0, func(interpreter *Interpreter, arguments []interface{}) interface{} {
+			return g
+		})
+	}
+
+	panic(RuntimeError{
+		Token:   name,
+		Message: fmt.Sprintf("Undefined property %s.", name.Lexeme),
+	})
+}
+
+func (g *LoxGenerator) String() string {
+	return fmt.Sprintf("<generator %s>", g.function.Declaration.Name.Lexeme)
+}
@@ -5,16 +5,55 @@ import "fmt"
 type LoxInstance struct {
 	Klass *LoxClass
 	Fields map[string]interface{}
+	// Private holds the `#name` fields separately for each declaring class, so they never
+	// collide with public fields or with a subclass's private fields
+	Private map[*LoxClass]map[string]interface{}
 }
 
 func NewLoxInstance(klass *LoxClass) *LoxInstance {
 	return &LoxInstance{
 		Klass: klass,
 		Fields: make(map[string]interface{}),
+		Private: make(map[*LoxClass]map[string]interface{}),
 	}
 }
 
-func (l *LoxInstance) get(name Token) interface{} {
+// privateFields returns the private fields instance holds for the class owner
+func (l *LoxInstance) privateFields(owner *LoxClass) map[string]interface{} {
+	fields, exists := l.Private[owner]
+	if !exists {
+		fields = make(map[string]interface{})
+		l.Private[owner] = fields
+	}
+	return fields
+}
+
+// getPrivate reads a private field or binds a private method declared by owner
+func (l *LoxInstance) getPrivate(owner *LoxClass, name Token) interface{} {
+	if value, exists := l.privateFields(owner)[name.Lexeme]; exists {
+		return value
+	}
+	if method, exists := owner.PrivateMethods[name.Lexeme]; exists {
+		return method.bind(l)
+	}
+
+	panic(RuntimeError{
+		Token: name,
+		Message: fmt.Sprintf("Undefined private member %s.", name.Lexeme),
+	})
+}
+
+func (l *LoxInstance) setPrivate(owner *LoxClass, name Token, value interface{}) {
+	if _, exists := owner.PrivateMethods[name.Lexeme]; exists {
+		panic(RuntimeError{
+			Token: name,
+			Message: fmt.Sprintf("Can't assign to private method %s.", name.Lexeme),
+		})
+	}
+	l.privateFields(owner)[name.Lexeme] = value
+}
+
+func (l *LoxInstance) get(interpreter *Interpreter, name Token) interface{} {
 	if _, exists := l.Fields[name.Lexeme]; exists {
 		return l.Fields[name.Lexeme]
 	}
@@ -22,6 +61,9 @@ func (l *LoxInstance) get(name Token) interface{} {
 	method := l.Klass.findMethod(name.Lexeme) 
 	
 	if method != nil {
+		if method.Declaration.IsGetter {
+			return method.bind(l).call(interpreter, []interface{}{})
+		}
 		return method.bind(l)
 	}
 
@@ -31,7 +73,21 @@ func (l *LoxInstance) get(name Token) interface{} {
 	})
 }
 
-func (l *LoxInstance) set(name Token, value interface{}) {
+// set runs the property's setter if the class has one. Otherwise it stores a field,
+// which isn't allowed for a property that only has a getter.
+func (l *LoxInstance) set(interpreter *Interpreter, name Token, value interface{}) {
+	if setter := l.Klass.findSetter(name.Lexeme); setter != nil {
+		setter.bind(l).call(interpreter, []interface{}{value})
+		return
+	}
+
+	if method := l.Klass.findMethod(name.Lexeme); method != nil && method.Declaration.IsGetter {
+		panic(RuntimeError{
+			Token: name,
+			Message: fmt.Sprintf("Can't set property %s, it only has a getter.", name.Lexeme),
+		})
+	}
+
 	l.Fields[name.Lexeme] = value 
 }
 
@@ -0,0 +1,128 @@
+package main
+
+import "fmt"
+
+// LoxIterator walks the elements of an iterable value, one per `for-in` iteration
+type LoxIterator interface {
+	hasNext() bool
+	next() interface{}
+}
+
+// listIterator follows the list as it is, so elements pushed while looping are visited
+type listIterator struct {
+	list     *LoxList
+	position int
+}
+
+func (l *listIterator) hasNext() bool {
+	return l.position < len(l.list.Elements)
+}
+
+func (l *listIterator) next() interface{} {
+	element := l.list.Elements[l.position]
+	l.position++
+	return element
+}
+
+// sliceIterator walks a snapshot, used for map keys and string characters
+type sliceIterator struct {
+	elements []interface{}
+	position int
+}
+
+func (s *sliceIterator) hasNext() bool {
+	return s.position < len(s.elements)
+}
+
+func (s *sliceIterator) next() interface{} {
+	element := s.elements[s.position]
+	s.position++
+	return element
+}
+
+type rangeIterator struct {
+	current int64
+	end     int64
+}
+
+func (r *rangeIterator) hasNext() bool {
+	return r.current < r.end
+}
+
+func (r *rangeIterator) next() interface{} {
+	value := r.current
+	r.current++
+	return value
+}
+
+// protocolIterator drives a Lox object implementing `hasNext()` and `next()`
+type protocolIterator struct {
+	interpreter *Interpreter
+	object      interface{}
+	token       Token
+}
+
+func (p *protocolIterator) hasNext() bool {
+	return p.interpreter.isTruthy(p.interpreter.callProtocolMethod(p.object, "hasNext", p.token))
+}
+
+func (p *protocolIterator) next() interface{} {
+	return p.interpreter.callProtocolMethod(p.object, "next", p.token)
+}
+
+// iterator returns an iterator over value, reporting values that can't be iterated at token
+func (i *Interpreter) iterator(value interface{}, token Token) LoxIterator {
+	switch iterable := value.(type) {
+	case *LoxList:
+		return &listIterator{list: iterable}
+	case *LoxMap:
+		return &sliceIterator{elements: iterable.keys()}
+	case string:
+		characters := make([]interface{}, 0, len(iterable))
+		for _, character := range iterable {
+			characters = append(characters, string(character))
+		}
+		return &sliceIterator{elements: characters}
+	case *LoxRange:
+		return &rangeIterator{current: iterable.Start, end: iterable.End}
+	case *LoxGenerator:
+		return iterable
+	case *LoxChannel:
+		return &channelIterator{interpreter: i, channel: iterable, token: token}
+	case *LoxEnum:
+		return &sliceIterator{elements: iterable.values()}
+	case *LoxInstance:
+		if iterable.Klass.findMethod("iterator") != nil {
+			return &protocolIterator{
+				interpreter: i,
+				object:      i.callProtocolMethod(iterable, "iterator", token),
+				token:       token,
+			}
+		}
+	}
+
+	panic(RuntimeError{
+		Token:   token,
+		Message: "Can only iterate over lists, maps, strings, ranges, generators, channels, enums and objects with an iterator() method.",
+	})
+}
+
+// callProtocolMethod calls the method name of object without arguments, on behalf of the
+// language construct at token
+func (i *Interpreter) callProtocolMethod(object interface{}, name string, token Token) interface{} {
+	property := token
+	property.TokenType = IDENTIFIER
+	property.Lexeme = name
+
+	method, ok := i.getProperty(object, property).(LoxCallable)
+	if !ok {
+		panic(RuntimeError{
+			Token:   token,
+			Message: fmt.Sprintf("'%s' must be a method.", name),
+		})
+	}
+	i.checkArity(method, 0, token)
+
+	defer i.blameNativeError(token)
+	return method.call(i, []interface{}{})
+}
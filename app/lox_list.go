@@ -0,0 +1,72 @@
+package main
+
+import "fmt"
+
+// LoxList is the built-in list value
+type LoxList struct {
+	Elements []interface{}
+}
+
+func NewLoxList(elements []interface{}) *LoxList {
+	return &LoxList{
+		Elements: elements,
+	}
+}
+
+func (l *LoxList) get(name Token) interface{} {
+	switch name.Lexeme {
+	case "length":
+		return NewNativeFunction("length", 0, func(interpreter *Interpreter, arguments []interface{}) interface{} {
+			return int64(len(l.Elements))
+		})
+	case "get":
+		return NewNativeFunction("get", 1, func(interpreter *Interpreter, arguments []interface{}) interface{} {
+			return l.Elements[l.index(arguments[0])]
+		})
+	case "set":
+		return NewNativeFunction("set", 2, func(interpreter *Interpreter, arguments []interface{}) interface{} {
+			l.Elements[l.index(arguments[0])] = arguments[1]
+			return arguments[1]
+		})
+	case "push":
+		return NewNativeFunction("push", 1, func(interpreter *Interpreter, arguments []interface{}) interface{} {
+			l.Elements = append(l.Elements, arguments[0])
+			return nil
+		})
+	case "pop":
+		return NewNativeFunction("pop", 0, func(interpreter *Interpreter, arguments []interface{}) interface{} {
+			if len(l.Elements) == 0 {
+				panic(&NativeError{Message: "Can't pop from an empty list."})
+			}
+			last := l.Elements[len(l.Elements)-1]
+			l.Elements = l.Elements[:len(l.Elements)-1]
+			return last
+		})
+	}
+
+	panic(RuntimeError{
+		Token:   name,
+		Message: fmt.Sprintf("Undefined property %s.", name.Lexeme),
+	})
+}
+
+// index checks that value is a valid index into the list. Negative indexes count from the end.
+func (l *LoxList) index(value interface{}) int {
+	return checkIndex(value, len(l.Elements), "List")
+}
+
+// checkIndex checks that value is a valid index into a sequence of length elements.
+// kind names the sequence in errors.
+func checkIndex(value interface{}, length int, kind string) int {
+	index, ok := value.(int64)
+	if !ok {
+		panic(&NativeError{Message: kind + " index must be an integer."})
+	}
+	if index < 0 {
+		index += int64(length)
+	}
+	if index < 0 || index >= int64(length) {
+		panic(&NativeError{Message: kind + " index out of range."})
+	}
+	return int(index)
+}
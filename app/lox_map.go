@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// LoxMap is the built-in map value. It remembers the order keys were first inserted in,
+// which is the order it iterates and prints in.
+type LoxMap struct {
+	entries []mapEntry
+	index   map[interface{}]int
+}
+
+type mapEntry struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// bigIntKey keys big integers by value rather than by pointer
+type bigIntKey string
+
+func NewLoxMap() *LoxMap {
+	return &LoxMap{
+		entries: make([]mapEntry, 0),
+		index:   make(map[interface{}]int),
+	}
+}
+
+// mapKey turns a Lox value into a Go map key. Numbers that compare equal share a key,
+// every other value is keyed like Go compares it, so objects are keyed by identity.
+func mapKey(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64:
+		if integer, ok := toIntegral(v); ok {
+			return mapKey(normalizeInteger(integer))
+		}
+	case *big.Int:
+		return bigIntKey(v.String())
+	}
+	return value
+}
+
+func (m *LoxMap) lookup(key interface{}) (interface{}, bool) {
+	if position, exists := m.index[mapKey(key)]; exists {
+		return m.entries[position].Value, true
+	}
+	return nil, false
+}
+
+func (m *LoxMap) put(key interface{}, value interface{}) {
+	if position, exists := m.index[mapKey(key)]; exists {
+		m.entries[position].Value = value
+		return
+	}
+	m.index[mapKey(key)] = len(m.entries)
+	m.entries = append(m.entries, mapEntry{Key: key, Value: value})
+}
+
+func (m *LoxMap) remove(key interface{}) (interface{}, bool) {
+	position, exists := m.index[mapKey(key)]
+	if !exists {
+		return nil, false
+	}
+	value := m.entries[position].Value
+	delete(m.index, mapKey(key))
+	m.entries = append(m.entries[:position], m.entries[position+1:]...)
+	for index := position; index < len(m.entries); index++ {
+		m.index[mapKey(m.entries[index].Key)] = index
+	}
+	return value, true
+}
+
+// keys returns a snapshot of the keys in insertion order
+func (m *LoxMap) keys() []interface{} {
+	keys := make([]interface{}, 0, len(m.entries))
+	for _, entry := range m.entries {
+		keys = append(keys, entry.Key)
+	}
+	return keys
+}
+
+func (m *LoxMap) get(name Token) interface{} {
+	switch name.Lexeme {
+	case "length":
+		return NewNativeFunction("length", 0, func(interpreter *Interpreter, arguments []interface{}) interface{} {
+			return int64(len(m.entries))
+		})
+	case "keys":
+		return NewNativeFunction("keys", 0, func(interpreter *Interpreter, arguments []interface{}) interface{} {
+			return NewLoxList(m.keys())
+		})
+	case "values":
+		return NewNativeFunction("values", 0, func(interpreter *Interpreter, arguments []interface{}) interface{} {
+			values := make([]interface{}, 0, len(m.entries))
+			for _, entry := range m.entries {
+				values = append(values, entry.Value)
+			}
+			return NewLoxList(values)
+		})
+	case "has":
+		return NewNativeFunction("has", 1, func(interpreter *Interpreter, arguments []interface{}) interface{} {
+			_, exists := m.lookup(arguments[0])
+			return exists
+		})
+	case "get":
+		// get returns nil, or the optional second argument, for a missing key
+		return &NativeFunction{Name: "get", Min: 1, Max: 2, Function: func(interpreter *Interpreter, arguments []interface{}) interface{} {
+			if value, exists := m.lookup(arguments[0]); exists {
+				return value
+			}
+			if len(arguments) > 1 {
+				return arguments[1]
+			}
+			return nil
+		}}
+	case "set":
+		return NewNativeFunction("set", 2, func(interpreter *Interpreter, arguments []interface{}) interface{} {
+			m.put(arguments[0], arguments[1])
+			return arguments[1]
+		})
+	case "remove":
+		return NewNativeFunction("remove", 1, func(interpreter *Interpreter, arguments []interface{}) interface{} {
+			value, _ := m.remove(arguments[0])
+			return value
+		})
+	}
+
+	panic(RuntimeError{
+		Token:   name,
+		Message: fmt.Sprintf("Undefined property %s.", name.Lexeme),
+	})
+}
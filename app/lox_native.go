@@ -0,0 +1,31 @@
+package main
+
+// NativeFunction is a callable implemented in Go, used for the methods of built-in
+// values. Max is -1 when it accepts any number of arguments.
+type NativeFunction struct {
+	Name     string
+	Min      int
+	Max      int
+	Function func(interpreter *Interpreter, arguments []interface{}) interface{}
+}
+
+func NewNativeFunction(name string, arity int, function func(interpreter *Interpreter, arguments []interface{}) interface{}) *NativeFunction {
+	return &NativeFunction{
+		Name:     name,
+		Min:      arity,
+		Max:      arity,
+		Function: function,
+	}
+}
+
+func (n *NativeFunction) arity() (int, int) {
+	return n.Min, n.Max
+}
+
+func (n *NativeFunction) call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	return n.Function(interpreter, arguments)
+}
+
+func (n *NativeFunction) String() string {
+	return "<native fn>"
+}
@@ -0,0 +1,150 @@
+package main
+
+type promiseState int
+
+const (
+	PROMISE_PENDING promiseState = iota
+	PROMISE_FULFILLED
+	PROMISE_REJECTED
+)
+
+// LoxPromise is the eventual result of an async function call
+type LoxPromise struct {
+	loop  *EventLoop
+	state promiseState
+	value interface{}
+	// err is the runtime error the call failed with
+	err interface{}
+	// reactions run on the event loop once the promise settles
+	reactions []func()
+	// handled is set once something awaits the promise, so its error isn't lost
+	handled bool
+}
+
+func NewLoxPromise(loop *EventLoop) *LoxPromise {
+	return &LoxPromise{
+		loop:      loop,
+		reactions: make([]func(), 0),
+	}
+}
+
+// resolve fulfills the promise with value, or makes it follow value if that is a promise
+func (p *LoxPromise) resolve(value interface{}) {
+	if p.state != PROMISE_PENDING {
+		return
+	}
+	if other, ok := value.(*LoxPromise); ok {
+		other.then(func() {
+			if other.state == PROMISE_REJECTED {
+				p.reject(other.err)
+			} else {
+				p.resolve(other.value)
+			}
+		})
+		return
+	}
+
+	p.state = PROMISE_FULFILLED
+	p.value = value
+	p.settle()
+}
+
+func (p *LoxPromise) reject(err interface{}) {
+	if p.state != PROMISE_PENDING {
+		return
+	}
+	p.state = PROMISE_REJECTED
+	p.err = err
+	p.loop.rejected = append(p.loop.rejected, p)
+	p.settle()
+}
+
+func (p *LoxPromise) settle() {
+	for _, reaction := range p.reactions {
+		p.loop.enqueue(reaction)
+	}
+	p.reactions = nil
+}
+
+// then runs reaction on the event loop once the promise has settled
+func (p *LoxPromise) then(reaction func()) {
+	p.handled = true
+	if p.state == PROMISE_PENDING {
+		p.reactions = append(p.reactions, reaction)
+		return
+	}
+	p.loop.enqueue(reaction)
+}
+
+func (p *LoxPromise) settled() bool {
+	return p.state != PROMISE_PENDING
+}
+
+// outcome is what an `await` of the settled promise resumes with
+func (p *LoxPromise) outcome() resumption {
+	if p.state == PROMISE_REJECTED {
+		return resumption{err: p.err}
+	}
+	return resumption{value: p.value}
+}
+
+func (p *LoxPromise) String() string {
+	switch p.state {
+	case PROMISE_FULFILLED:
+		return "<promise fulfilled>"
+	case PROMISE_REJECTED:
+		return "<promise rejected>"
+	}
+	return "<promise pending>"
+}
+
+// callAsync starts a call of an async function. The body runs right away, up to its
+// first `await`, and the returned promise settles once the body finishes.
+func callAsync(interpreter *Interpreter, function *LoxFunction, environment *Environment) *LoxPromise {
+	promise := NewLoxPromise(interpreter.loop)
+	routine := newCoroutine(interpreter, func(interpreter *Interpreter) interface{} {
+		return runFunctionBody(interpreter, function, environment)
+	})
+	routine.interpreter.async = routine
+
+	stepAsync(routine, promise, resumption{})
+	return promise
+}
+
+// stepAsync runs an async call up to its next `await`, which suspends it with the
+// awaited promise. It is resumed from the event loop once that promise settles.
+func stepAsync(routine *coroutine, promise *LoxPromise, with resumption) {
+	result := routine.resume(with)
+	switch {
+	case result.err != nil:
+		if _, ok := result.err.(RuntimeError); !ok {
+			panic(result.err)
+		}
+		promise.reject(result.err)
+	case result.done:
+		promise.resolve(result.value)
+	default:
+		awaited := result.value.(*LoxPromise)
+		awaited.then(func() {
+			stepAsync(routine, promise, awaited.outcome())
+		})
+	}
+}
+
+// awaitPromise waits for promise outside of an async function by running the event loop
+// until it settles
+func (i *Interpreter) awaitPromise(promise *LoxPromise, keyword Token) interface{} {
+	promise.handled = true
+	if !i.loop.run(i, promise.settled) {
+		panic(RuntimeError{
+			Token:   keyword,
+			Message: "Awaited promise can never settle.",
+		})
+	}
+
+	outcome := promise.outcome()
+	if outcome.err != nil {
+		panic(outcome.err)
+	}
+	return outcome.value
+}
@@ -0,0 +1,20 @@
+package main
+
+import "fmt"
+
+// LoxRange is `Start..End`, the integers from Start up to but not including End
+type LoxRange struct {
+	Start int64
+	End   int64
+}
+
+func NewLoxRange(start int64, end int64) *LoxRange {
+	return &LoxRange{
+		Start: start,
+		End:   end,
+	}
+}
+
+func (r *LoxRange) String() string {
+	return fmt.Sprintf("%d..%d", r.Start, r.End)
+}
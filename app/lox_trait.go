@@ -0,0 +1,36 @@
+package main
+
+// LoxTrait is a named set of methods that classes mix in with `with`. Its methods are
+// copied into every class using it, so they only get a `this` and a `super` once mixed in.
+type LoxTrait struct {
+	Name    string
+	Methods []*FunctionStatement
+	Setters []*FunctionStatement
+	// Closure is the environment the trait was declared in
+	Closure *Environment
+}
+
+func NewLoxTrait(name string, methods []*FunctionStatement, setters []*FunctionStatement, closure *Environment) *LoxTrait {
+	return &LoxTrait{
+		Name:    name,
+		Methods: methods,
+		Setters: setters,
+		Closure: closure,
+	}
+}
+
+// environment creates the environment the trait's methods close over once mixed into a
+// class, where `super` is that class's superclass. superclass is nil when there is none.
+func (t *LoxTrait) environment(superclass *LoxClass) *Environment {
+	environment := NewEnclosedEnvironment(t.Closure)
+	if superclass != nil {
+		environment.define("super", superclass)
+	} else {
+		environment.define("super", nil)
+	}
+	return environment
+}
+
+func (t *LoxTrait) String() string {
+	return t.Name
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 )
 
 var hasError = false
@@ -11,24 +12,52 @@ var hasRuntimeError = false
 func main() {
 	fmt.Fprintln(os.Stderr, "Logs from your program will appear here!")
 
-	if len(os.Args) < 3 {
-		fmt.Fprintln(os.Stderr, "Usage: ./your_program.sh tokenize <filename>")
+	args := make([]string, 0, len(os.Args))
+	diagnosticFormat := DIAGNOSTICS_TEXT
+	fakeClock := false
+	tailCalls := true
+	for _, arg := range os.Args[1:] {
+		if arg == "--fake-clock" {
+			fakeClock = true
+			continue
+		}
+		if arg == "--no-tco" {
+			tailCalls = false
+			continue
+		}
+		if value, ok := strings.CutPrefix(arg, "--diagnostics="); ok {
+			format, valid := parseDiagnosticFormat(value)
+			if !valid {
+				fmt.Fprintf(os.Stderr, "Unknown diagnostics format: %s\n", value)
+				os.Exit(1)
+			}
+			diagnosticFormat = format
+			continue
+		}
+		args = append(args, arg)
+	}
+
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "Usage: ./your_program.sh tokenize <filename> [--diagnostics=text|json|sarif] [--fake-clock] [--no-tco]")
 		os.Exit(1)
 	}
 
-	command := os.Args[1]
-	if !(command == "tokenize" || command == "parse" || command == "evaluate" || command == "run") {
+	command := args[0]
+	if !(command == "tokenize" || command == "parse" || command == "evaluate" || command == "run" || command == "check") {
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", command)
 		os.Exit(1)
 	}
 
-	filename := os.Args[2]
+	filename := args[1]
 	fileContents, err := os.ReadFile(filename)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
 		os.Exit(1)
 	}
 
+	reporter = NewDiagnosticReporter(diagnosticFormat, filename)
+	defer reporter.flush()
+
 	source := string(fileContents)
 	scanner := &Scanner{
 		source:  source,
@@ -44,12 +73,13 @@ func main() {
 		printTokens(tokens)
 
 		if hasError {
-			os.Exit(65)
+			exit(65)
 		}
 		return
 	}
 
 	parser := NewParser(tokens)
+	parser.expressionMode = command == "parse" || command == "evaluate"
 	statements := parser.parse()
 
 	if command == "parse" {
@@ -57,25 +87,42 @@ func main() {
 		fmt.Println(astPrinter.Print(statements))
 
 		if hasError {
-			os.Exit(65) // Exit with code 65 for compile errors
+			exit(65) // Exit with code 65 for compile errors
+		}
+		return
+	}
+
+	if command == "check" {
+		if hasError {
+			exit(65)
+		}
+		resolver := NewResolver(NewInterpreter(false))
+		resolver.resolve(statements)
+		if !hasError {
+			NewTypeChecker().check(statements)
+		}
+		if hasError {
+			exit(65)
 		}
 		return
 	}
 
 	if command == "run" || command == "evaluate" {
 		if hasError {
-			os.Exit(65) // Exit immediately if parse errors exist
+			exit(65) // Exit immediately if parse errors exist
 		}
 		interpreter := NewInterpreter(command == "evaluate")
+		interpreter.loop.fake = fakeClock
+		interpreter.noTailCalls = !tailCalls
 		resolver := NewResolver(interpreter)
 		resolver.resolve(statements)
 		if hasError {
-			os.Exit(65)
+			exit(65)
 		}
 		interpreter.interpret(statements)
 
 		if hasRuntimeError {
-			os.Exit(70)
+			exit(70)
 		}
 	}
 }
@@ -86,6 +133,9 @@ func printTokens(tokens []Token) {
 		var LiteralStr string
 		if token.Literal == nil {
 			LiteralStr = "null"
+		} else if isInteger(token.Literal) {
+			// Integers are shown like whole floats to keep the classic Lox output
+			LiteralStr = fmt.Sprintf("%v.0", token.Literal)
 		} else if num, ok := token.Literal.(float64); ok {
 			if token.TokenType == NUMBER {
 				if num == float64(int(num)) {
@@ -105,11 +155,30 @@ func printTokens(tokens []Token) {
 	}
 }
 
-func error(Line int, message string) {
-	report(Line, "", message)
+// exit flushes pending diagnostics before terminating with the given status code
+func exit(code int) {
+	reporter.flush()
+	os.Exit(code)
+}
+
+func error(Line int, Column int, message string, related ...RelatedLocation) {
+	report(Diagnostic{
+		Severity: SEVERITY_ERROR,
+		Code:     CODE_SCAN_ERROR,
+		Location: Location{Line: Line, Column: Column},
+		Message:  message,
+		Related:  related,
+	}, "")
 }
 
-func report(Line int, where string, message string) {
-	fmt.Fprintf(os.Stderr, "[line %d] Error%s: %s\n", Line, where, message)
-	hasError = true
+// report records a diagnostic. Only errors make the command fail, warnings are printed.
+func report(diagnostic Diagnostic, where string) {
+	label := "Error"
+	if diagnostic.Severity == SEVERITY_WARNING {
+		label = "Warning"
+	}
+	reporter.add(diagnostic, fmt.Sprintf("[line %d] %s%s: %s\n", diagnostic.Line, label, where, diagnostic.Message))
+	if diagnostic.Severity == SEVERITY_ERROR {
+		hasError = true
+	}
 }
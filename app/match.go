@@ -0,0 +1,163 @@
+package main
+
+import "fmt"
+
+func (i *Interpreter) visitMatchStmt(stmt *MatchStatement) interface{} {
+	subject := i.evaluate(stmt.Subject)
+	for _, arm := range stmt.Arms {
+		if environment, ok := i.matchArm(arm, subject); ok {
+			i.executeBlock([]Stmt{arm.Body}, environment)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (i *Interpreter) visitMatchExpr(expr *MatchExpr) interface{} {
+	subject := i.evaluate(expr.Subject)
+	for _, arm := range expr.Arms {
+		if environment, ok := i.matchArm(arm, subject); ok {
+			return i.evaluateIn(arm.Value, environment)
+		}
+	}
+
+	panic(RuntimeError{
+		Token:   expr.Keyword,
+		Message: fmt.Sprintf("No match arm matches %s.", i.stringify(subject)),
+	})
+}
+
+// matchArm tests value against the pattern and guard of arm. Every arm gets its own
+// environment, which the arm's body runs in when it matches.
+func (i *Interpreter) matchArm(arm *MatchArm, value interface{}) (*Environment, bool) {
+	environment := NewEnclosedEnvironment(i.environment)
+	previousEnvironment := i.environment
+	i.environment = environment
+
+	defer func() {
+		i.environment = previousEnvironment
+	}()
+
+	if !i.matchPattern(arm.Pattern, value) {
+		return nil, false
+	}
+	if arm.Guard != nil && !i.isTruthy(i.evaluate(arm.Guard)) {
+		return nil, false
+	}
+	return environment, true
+}
+
+func (i *Interpreter) visitDestructureStmt(stmt *DestructureStatement) interface{} {
+	value := i.evaluate(stmt.Initializer)
+	if !i.matchPattern(stmt.Pattern, value) {
+		panic(RuntimeError{
+			Token:   stmt.Keyword,
+			Message: fmt.Sprintf("Can't destructure %s, it doesn't match the pattern.", i.stringify(value)),
+		})
+	}
+	return nil
+}
+
+// matchPattern tests value against pattern, defining the names the pattern binds in the
+// current environment as it goes
+func (i *Interpreter) matchPattern(pattern Pattern, value interface{}) bool {
+	switch pattern := pattern.(type) {
+	case *WildcardPattern:
+		return true
+	case *LiteralPattern:
+		return i.isEqual(value, pattern.Value)
+	case *ValuePattern:
+		return i.isEqual(value, i.evaluate(pattern.Value))
+	case *BindingPattern:
+		i.environment.declare(pattern.Name, value)
+		return true
+	case *ListPattern:
+		return i.matchList(pattern, value)
+	case *MapPattern:
+		return i.matchMap(pattern, value)
+	case *ClassPattern:
+		return i.matchInstance(pattern, value)
+	}
+	return false
+}
+
+func (i *Interpreter) matchList(pattern *ListPattern, value interface{}) bool {
+	list, ok := value.(*LoxList)
+	if !ok || len(list.Elements) < len(pattern.Elements) {
+		return false
+	}
+	if pattern.Rest == nil && len(list.Elements) != len(pattern.Elements) {
+		return false
+	}
+
+	for index, element := range pattern.Elements {
+		if !i.matchPattern(element, list.Elements[index]) {
+			return false
+		}
+	}
+	if pattern.Rest != nil && pattern.Rest.Lexeme != "_" {
+		rest := make([]interface{}, len(list.Elements)-len(pattern.Elements))
+		copy(rest, list.Elements[len(pattern.Elements):])
+		i.environment.declare(*pattern.Rest, NewLoxList(rest))
+	}
+	return true
+}
+
+func (i *Interpreter) matchMap(pattern *MapPattern, value interface{}) bool {
+	loxMap, ok := value.(*LoxMap)
+	if !ok {
+		return false
+	}
+
+	for index, key := range pattern.Keys {
+		entry, exists := loxMap.lookup(key.Value)
+		if !exists || !i.matchPattern(pattern.Values[index], entry) {
+			return false
+		}
+	}
+	return true
+}
+
+func (i *Interpreter) matchInstance(pattern *ClassPattern, value interface{}) bool {
+	class, ok := i.evaluate(pattern.Class).(*LoxClass)
+	if !ok {
+		panic(RuntimeError{
+			Token:   pattern.Paren,
+			Message: "Can only match instances against a class.",
+		})
+	}
+
+	names := make([]string, 0, len(pattern.Positional)+len(pattern.Names))
+	fields := make([]Pattern, 0, len(pattern.Positional)+len(pattern.Named))
+	fields = append(append(fields, pattern.Positional...), pattern.Named...)
+	if len(pattern.Positional) > 0 {
+		var params []Token
+		if initializer := class.findMethod("init"); initializer != nil {
+			params = initializer.Declaration.Params
+		}
+		if len(pattern.Positional) > len(params) {
+			panic(RuntimeError{
+				Token:   pattern.Paren,
+				Message: fmt.Sprintf("Expected at most %d positional patterns for %s but got %d.", len(params), class.Name, len(pattern.Positional)),
+			})
+		}
+		for index := range pattern.Positional {
+			names = append(names, params[index].Lexeme)
+		}
+	}
+	for _, name := range pattern.Names {
+		names = append(names, name.Lexeme)
+	}
+
+	instance, ok := value.(*LoxInstance)
+	if !ok || !instance.Klass.isSubclassOf(class) {
+		return false
+	}
+	for index, field := range fields {
+		fieldValue, exists := instance.Fields[names[index]]
+		if !exists || !i.matchPattern(field, fieldValue) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// Lox numbers are int64 for integers, *big.Int for integers that overflow an int64 and
+// float64 for everything else. Integer arithmetic stays exact, mixing an integer with a
+// float promotes the integer to float64.
+
+func isNumber(value interface{}) bool {
+	switch value.(type) {
+	case int64, *big.Int, float64:
+		return true
+	}
+	return false
+}
+
+func isInteger(value interface{}) bool {
+	switch value.(type) {
+	case int64, *big.Int:
+		return true
+	}
+	return false
+}
+
+func isZero(value interface{}) bool {
+	switch v := value.(type) {
+	case int64:
+		return v == 0
+	case *big.Int:
+		return v.Sign() == 0
+	case float64:
+		return v == 0
+	}
+	return false
+}
+
+func toFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case int64:
+		return float64(v)
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return f
+	case float64:
+		return v
+	}
+	return 0
+}
+
+func toBigInt(value interface{}) *big.Int {
+	switch v := value.(type) {
+	case int64:
+		return big.NewInt(v)
+	case *big.Int:
+		return v
+	}
+	return nil
+}
+
+// toIntegral returns an integer, or a float without a fractional part, as a big integer
+func toIntegral(value interface{}) (*big.Int, bool) {
+	if isInteger(value) {
+		return toBigInt(value), true
+	}
+	f, ok := value.(float64)
+	if !ok || math.IsInf(f, 0) || f != math.Trunc(f) {
+		return nil, false
+	}
+	integer, _ := new(big.Float).SetFloat64(f).Int(nil)
+	return integer, true
+}
+
+// normalizeInteger demotes a big integer to int64 when it fits
+func normalizeInteger(value *big.Int) interface{} {
+	if value.IsInt64() {
+		return value.Int64()
+	}
+	return value
+}
+
+// arithmetic applies + - * / % div or ** to two numbers. Division and modulo by zero
+// are checked by the caller, except for '/', which follows float semantics.
+func arithmetic(operator TokenType, left interface{}, right interface{}) interface{} {
+	if isInteger(left) && isInteger(right) {
+		return integerArithmetic(operator, left, right)
+	}
+	return floatArithmetic(operator, toFloat(left), toFloat(right))
+}
+
+func integerArithmetic(operator TokenType, left interface{}, right interface{}) interface{} {
+	l, leftSmall := left.(int64)
+	r, rightSmall := right.(int64)
+	if leftSmall && rightSmall {
+		switch operator {
+		case PLUS:
+			if sum := l + r; (sum > l) == (r > 0) {
+				return sum
+			}
+		case MINUS:
+			if difference := l - r; (difference < l) == (r > 0) {
+				return difference
+			}
+		case STAR:
+			if l == 0 || r == 0 {
+				return int64(0)
+			}
+			if product := l * r; product/r == l && !(l == -1 && r == math.MinInt64) && !(r == -1 && l == math.MinInt64) {
+				return product
+			}
+		}
+	}
+
+	a, b := toBigInt(left), toBigInt(right)
+	switch operator {
+	case PLUS:
+		return normalizeInteger(new(big.Int).Add(a, b))
+	case MINUS:
+		return normalizeInteger(new(big.Int).Sub(a, b))
+	case STAR:
+		return normalizeInteger(new(big.Int).Mul(a, b))
+	case SLASH:
+		if b.Sign() == 0 {
+			return floatArithmetic(SLASH, toFloat(left), toFloat(right))
+		}
+		quotient, remainder := new(big.Int).QuoRem(a, b, new(big.Int))
+		if remainder.Sign() == 0 {
+			return normalizeInteger(quotient)
+		}
+		f, _ := new(big.Rat).SetFrac(a, b).Float64()
+		return f
+	case PERCENT:
+		return normalizeInteger(new(big.Int).Rem(a, b))
+	case DIV:
+		return normalizeInteger(new(big.Int).Quo(a, b))
+	case STAR_STAR:
+		if b.Sign() < 0 {
+			return floatArithmetic(STAR_STAR, toFloat(left), toFloat(right))
+		}
+		return normalizeInteger(new(big.Int).Exp(a, b, nil))
+	}
+	return nil
+}
+
+// powerBits is at least how many bits the integer power base ** exponent takes, or 0 when
+// it isn't an integer power or can't grow, as for a base of 0, 1 or -1
+func powerBits(base interface{}, exponent interface{}) int64 {
+	if !isInteger(base) || !isInteger(exponent) {
+		return 0
+	}
+	a, b := toBigInt(base), toBigInt(exponent)
+	if b.Sign() <= 0 || a.CmpAbs(big.NewInt(1)) <= 0 {
+		return 0
+	}
+	if !b.IsInt64() {
+		return math.MaxInt64
+	}
+	bits := int64(a.BitLen() - 1)
+	if b.Int64() > math.MaxInt64/bits {
+		return math.MaxInt64
+	}
+	return bits * b.Int64()
+}
+
+func floatArithmetic(operator TokenType, left float64, right float64) interface{} {
+	switch operator {
+	case PLUS:
+		return left + right
+	case MINUS:
+		return left - right
+	case STAR:
+		return left * right
+	case SLASH:
+		return left / right
+	case PERCENT:
+		return math.Mod(left, right)
+	case DIV:
+		return math.Trunc(left / right)
+	case STAR_STAR:
+		return math.Pow(left, right)
+	}
+	return nil
+}
+
+// bitwise applies & | ^ << or >> to two integers
+func bitwise(operator TokenType, left *big.Int, right *big.Int) interface{} {
+	result := new(big.Int)
+	switch operator {
+	case AMPERSAND:
+		result.And(left, right)
+	case PIPE:
+		result.Or(left, right)
+	case CARET:
+		result.Xor(left, right)
+	case LESS_LESS:
+		result.Lsh(left, uint(right.Uint64()))
+	case GREATER_GREATER:
+		result.Rsh(left, uint(right.Uint64()))
+	}
+	return normalizeInteger(result)
+}
+
+func negate(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int64:
+		if v == math.MinInt64 {
+			return new(big.Int).Neg(big.NewInt(v))
+		}
+		return -v
+	case *big.Int:
+		return normalizeInteger(new(big.Int).Neg(v))
+	case float64:
+		return -v
+	}
+	return nil
+}
+
+// compareNumbers orders two numbers exactly, even across kinds. ok is false when a NaN
+// makes them unordered.
+func compareNumbers(left interface{}, right interface{}) (result int, ok bool) {
+	if l, leftSmall := left.(int64); leftSmall {
+		if r, rightSmall := right.(int64); rightSmall {
+			switch {
+			case l < r:
+				return -1, true
+			case l > r:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+
+	if isInteger(left) && isInteger(right) {
+		return toBigInt(left).Cmp(toBigInt(right)), true
+	}
+
+	if math.IsNaN(toFloat(left)) || math.IsNaN(toFloat(right)) {
+		return 0, false
+	}
+	return toBigFloat(left).Cmp(toBigFloat(right)), true
+}
+
+func toBigFloat(value interface{}) *big.Float {
+	if f, ok := value.(float64); ok {
+		return new(big.Float).SetFloat64(f)
+	}
+	return new(big.Float).SetInt(toBigInt(value))
+}
+
+func numbersEqual(left interface{}, right interface{}) bool {
+	result, ok := compareNumbers(left, right)
+	return ok && result == 0
+}
+
+// formatNumber renders a number the way Lox prints it. Floats use the shortest
+// representation that round-trips, integral values drop the ".0", and very large or
+// very small magnitudes switch to exponent notation.
+func formatNumber(value interface{}) string {
+	switch v := value.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case *big.Int:
+		return v.String()
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "nan"
+		case math.IsInf(v, 1):
+			return "inf"
+		case math.IsInf(v, -1):
+			return "-inf"
+		}
+		magnitude := math.Abs(v)
+		if magnitude >= 1e21 || (magnitude < 1e-6 && magnitude != 0) {
+			return strconv.FormatFloat(v, 'e', -1, 64)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
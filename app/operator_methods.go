@@ -0,0 +1,47 @@
+package main
+
+// operatorMethods maps the binary operators a class can overload to the method
+// implementing them. The left operand is the receiver and the right one the argument.
+var operatorMethods = map[TokenType]string{
+	PLUS:          "__add__",
+	MINUS:         "__sub__",
+	STAR:          "__mul__",
+	SLASH:         "__div__",
+	PERCENT:       "__mod__",
+	LESS:          "__lt__",
+	LESS_EQUAL:    "__le__",
+	GREATER:       "__gt__",
+	GREATER_EQUAL: "__ge__",
+}
+
+// operatorMethodArguments is how many arguments each special method is called with
+var operatorMethodArguments = map[string]int{
+	"__add__":      1,
+	"__sub__":      1,
+	"__mul__":      1,
+	"__div__":      1,
+	"__mod__":      1,
+	"__lt__":       1,
+	"__le__":       1,
+	"__gt__":       1,
+	"__ge__":       1,
+	"__eq__":       1,
+	"__neg__":      0,
+	"__index__":    1,
+	"__setindex__": 2,
+	"__str__":      0,
+}
+
+// findOperatorMethod returns the special method name bound to value, or nil when value
+// isn't an instance or its class doesn't define the method
+func findOperatorMethod(value interface{}, name string) *LoxFunction {
+	instance, ok := value.(*LoxInstance)
+	if !ok {
+		return nil
+	}
+	method := instance.Klass.findMethod(name)
+	if method == nil {
+		return nil
+	}
+	return method.bind(instance)
+}
@@ -4,9 +4,30 @@ import (
 	"fmt"
 )
 
+// compoundOperators maps compound assignment, increment and decrement tokens to the
+// binary operator they apply
+var compoundOperators = map[TokenType]TokenType{
+	PLUS_EQUAL:    PLUS,
+	MINUS_EQUAL:   MINUS,
+	STAR_EQUAL:    STAR,
+	SLASH_EQUAL:   SLASH,
+	PERCENT_EQUAL: PERCENT,
+	PLUS_PLUS:     PLUS,
+	MINUS_MINUS:   MINUS,
+}
+
+// maxParseErrors bounds how many syntax errors are reported before the parser gives up
+const maxParseErrors = 50
+
 type Parser struct {
 	tokens  []Token
 	current int
+
+	// expressionMode lets the last statement omit its ';', as used by the parse and evaluate commands
+	expressionMode bool
+	// depth counts the enclosing '{' so synchronization can stop at the matching '}'
+	depth      int
+	errorCount int
 }
 
 func NewParser(tokens []Token) *Parser {
@@ -33,6 +54,23 @@ func (p *Parser) statement() Stmt {
 		return p.returnStatement()
 	}
 
+	if p.match(YIELD) {
+		return p.yieldStatement()
+	}
+
+	if p.match(SELECT) {
+		return p.selectStatement()
+	}
+
+	if p.match(MATCH) {
+		keyword, subject, arms := p.matchArms(true)
+		return &MatchStatement{
+			Keyword: keyword,
+			Subject: subject,
+			Arms:    arms,
+		}
+	}
+
 	if p.match(LEFT_BRACE) {
 		return &Block{
 			Statements: p.block(),
@@ -47,9 +85,16 @@ func (p *Parser) statement() Stmt {
 }
 
 func (p *Parser) block() []Stmt {
+	p.depth++
+	defer func() {
+		p.depth--
+	}()
+
 	statements := make([]Stmt, 0)
 	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
-		statements = append(statements, p.declaration())
+		if declaration := p.declaration(); declaration != nil {
+			statements = append(statements, declaration)
+		}
 	}
 	p.consume(RIGHT_BRACE, "Expect '}' after block.")
 	return statements
@@ -58,6 +103,10 @@ func (p *Parser) block() []Stmt {
 func (p *Parser) forStatement() Stmt {
 	p.consume(LEFT_PAREN, "Expect '(' after 'for'")
 
+	if p.isForIn() {
+		return p.forInStatement()
+	}
+
 	var initializer Stmt
 	if p.match(SEMICOLON) {
 		initializer = nil
@@ -67,20 +116,12 @@ func (p *Parser) forStatement() Stmt {
 		initializer = p.expressionStatement()
 	}
 
-	if hasError {
-		return &ExpressionStatement{Expression: nil}
-	}
-
 	var condition Expr
 	if !p.check(SEMICOLON) {
 		condition = p.expression()
 	}
 	p.consume(SEMICOLON, "Expect ';' after loop condition")
 
-	if hasError {
-		return &ExpressionStatement{Expression: nil}
-	}
-
 	var increment Expr
 	if !p.check(RIGHT_PAREN) {
 		increment = p.expression()
@@ -123,6 +164,35 @@ func (p *Parser) forStatement() Stmt {
 	return body
 }
 
+// isForIn looks ahead for `[var] name in`, which starts a for-in loop rather than a
+// C-style one. `in` is only a keyword there.
+func (p *Parser) isForIn() bool {
+	offset := 0
+	if p.check(VAR) {
+		offset = 1
+	}
+	if p.current+offset+1 >= len(p.tokens) {
+		return false
+	}
+	name, in := p.tokens[p.current+offset], p.tokens[p.current+offset+1]
+	return name.TokenType == IDENTIFIER && in.TokenType == IDENTIFIER && in.Lexeme == "in"
+}
+
+func (p *Parser) forInStatement() Stmt {
+	p.match(VAR)
+	name := p.consume(IDENTIFIER, "Expect loop variable name.")
+	in := p.advance()
+	iterable := p.expression()
+	p.consume(RIGHT_PAREN, "Expect ')' after for-in clause")
+
+	return &ForInStatement{
+		Name:     name,
+		In:       in,
+		Iterable: iterable,
+		Body:     p.statement(),
+	}
+}
+
 func (p *Parser) whileStatement() Stmt {
 	p.consume(LEFT_PAREN, "Expect '(' after 'while'")
 	condition := p.expression()
@@ -151,6 +221,251 @@ func (p *Parser) returnStatement() Stmt {
 	}
 }
 
+func (p *Parser) yieldStatement() Stmt {
+	keyword := p.previous()
+	var value Expr
+	if !p.check(SEMICOLON) {
+		value = p.expression()
+	}
+
+	p.consume(SEMICOLON, "Expect ';' after yield value.")
+
+	return &YieldStatement{
+		Keyword: keyword,
+		Value:   value,
+	}
+}
+
+func (p *Parser) selectStatement() Stmt {
+	keyword := p.previous()
+	p.consume(LEFT_BRACE, "Expect '{' after 'select'.")
+	p.depth++
+	defer func() {
+		p.depth--
+	}()
+
+	cases := make([]*SelectCase, 0)
+	hasDefault := false
+	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
+		selectCase := p.selectCase()
+		if selectCase.Kind.Lexeme == "default" {
+			if hasDefault {
+				p.error(selectCase.Kind, "A select can only have one default case.")
+			}
+			hasDefault = true
+		}
+		cases = append(cases, selectCase)
+	}
+
+	p.consume(RIGHT_BRACE, "Expect '}' after select cases.")
+	if len(cases) == 0 {
+		p.error(keyword, "A select needs at least one case.")
+	}
+
+	return &SelectStatement{
+		Keyword: keyword,
+		Cases:   cases,
+	}
+}
+
+// matchArms parses the rest of a match after its keyword. The arms of a statement have a
+// statement for body, those of an expression a value and are separated by commas.
+func (p *Parser) matchArms(statement bool) (Token, Expr, []*MatchArm) {
+	keyword := p.previous()
+	p.consume(LEFT_PAREN, "Expect '(' after 'match'.")
+	subject := p.expression()
+	p.consume(RIGHT_PAREN, "Expect ')' after match value.")
+	p.consume(LEFT_BRACE, "Expect '{' before match arms.")
+	p.depth++
+	defer func() {
+		p.depth--
+	}()
+
+	arms := make([]*MatchArm, 0)
+	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
+		arm := &MatchArm{
+			Pattern: p.pattern(),
+		}
+		if p.match(IF) {
+			arm.Guard = p.expression()
+		}
+		p.consume(ARROW, "Expect '=>' after pattern.")
+
+		arms = append(arms, arm)
+		if statement {
+			arm.Body = p.statement()
+			continue
+		}
+		arm.Value = p.expression()
+		if !p.match(COMMA) {
+			break
+		}
+	}
+
+	p.consume(RIGHT_BRACE, "Expect '}' after match arms.")
+	if len(arms) == 0 {
+		p.error(keyword, "A match needs at least one arm.")
+	}
+
+	return keyword, subject, arms
+}
+
+// pattern parses the pattern of a match arm or destructuring declaration: a literal, `_`,
+// a name to bind, a dotted name such as `Color.Red` standing for the value it names, or a
+// list, map or class pattern
+func (p *Parser) pattern() Pattern {
+	switch {
+	case p.match(LEFT_BRACKET):
+		return p.listPattern()
+	case p.match(LEFT_BRACE):
+		return p.mapPattern()
+	case p.match(NUMBER, STRING):
+		return &LiteralPattern{Token: p.previous(), Value: p.previous().Literal}
+	case p.match(TRUE):
+		return &LiteralPattern{Token: p.previous(), Value: true}
+	case p.match(FALSE):
+		return &LiteralPattern{Token: p.previous(), Value: false}
+	case p.match(NIL):
+		return &LiteralPattern{Token: p.previous(), Value: nil}
+	case p.match(MINUS):
+		minus := p.previous()
+		number := p.consume(NUMBER, "Expect number after '-' in pattern.")
+		return &LiteralPattern{Token: minus, Value: negate(number.Literal)}
+	case p.checkContextual("_"):
+		return &WildcardPattern{Token: p.advance()}
+	case p.match(IDENTIFIER):
+		if !p.check(DOT) && !p.check(LEFT_PAREN) {
+			return &BindingPattern{Name: p.previous()}
+		}
+		var value Expr = &VariableExpr{Name: p.previous()}
+		for p.match(DOT) {
+			value = &GetExpression{
+				Object: value,
+				Name:   p.consume(IDENTIFIER, "Expect property name after '.'."),
+			}
+		}
+		if p.match(LEFT_PAREN) {
+			return p.classPattern(value)
+		}
+		return &ValuePattern{Value: value}
+	}
+
+	panic(p.error(p.peek(), "Expect pattern."))
+}
+
+func (p *Parser) listPattern() Pattern {
+	pattern := &ListPattern{
+		Bracket:  p.previous(),
+		Elements: make([]Pattern, 0),
+	}
+	if !p.check(RIGHT_BRACKET) {
+		for {
+			if p.match(ELLIPSIS) {
+				rest := p.consume(IDENTIFIER, "Expect name after '...'.")
+				pattern.Rest = &rest
+				break
+			}
+			pattern.Elements = append(pattern.Elements, p.pattern())
+			if !p.match(COMMA) {
+				break
+			}
+		}
+	}
+	if pattern.Rest != nil && p.check(COMMA) {
+		panic(p.error(p.peek(), "A rest pattern must be last."))
+	}
+	p.consume(RIGHT_BRACKET, "Expect ']' after list pattern.")
+	return pattern
+}
+
+func (p *Parser) mapPattern() Pattern {
+	pattern := &MapPattern{
+		Brace:  p.previous(),
+		Keys:   make([]*LiteralPattern, 0),
+		Values: make([]Pattern, 0),
+	}
+	if !p.check(RIGHT_BRACE) {
+		for {
+			key, ok := p.pattern().(*LiteralPattern)
+			if !ok {
+				p.error(p.previous(), "Map pattern keys must be literals.")
+			}
+			p.consume(COLON, "Expect ':' after map pattern key.")
+			value := p.pattern()
+			// A bad key was reported, the rest of the pattern is still worth checking
+			if ok {
+				pattern.Keys = append(pattern.Keys, key)
+				pattern.Values = append(pattern.Values, value)
+			}
+			if !p.match(COMMA) {
+				break
+			}
+		}
+	}
+	p.consume(RIGHT_BRACE, "Expect '}' after map pattern.")
+	return pattern
+}
+
+// classPattern parses the field patterns of `Class(...)`, whose '(' was just matched.
+// Named patterns come after the positional ones, like named arguments.
+func (p *Parser) classPattern(class Expr) Pattern {
+	pattern := &ClassPattern{
+		Class:      class,
+		Paren:      p.previous(),
+		Positional: make([]Pattern, 0),
+		Names:      make([]Token, 0),
+		Named:      make([]Pattern, 0),
+	}
+	if !p.check(RIGHT_PAREN) {
+		for {
+			if p.check(IDENTIFIER) && p.peekNext().TokenType == COLON {
+				pattern.Names = append(pattern.Names, p.advance())
+				p.advance()
+				pattern.Named = append(pattern.Named, p.pattern())
+			} else {
+				if len(pattern.Named) > 0 {
+					p.error(p.peek(), "Positional patterns must come before named patterns.")
+				}
+				pattern.Positional = append(pattern.Positional, p.pattern())
+			}
+			if !p.match(COMMA) {
+				break
+			}
+		}
+	}
+	p.consume(RIGHT_PAREN, "Expect ')' after class pattern.")
+	return pattern
+}
+
+// selectCase parses one case of a select. Its words are only keywords in that position.
+func (p *Parser) selectCase() *SelectCase {
+	selectCase := &SelectCase{}
+
+	switch {
+	case p.checkContextual("receive"):
+		selectCase.Kind = p.advance()
+		if p.check(IDENTIFIER) && p.peekNext().TokenType == IDENTIFIER && p.peekNext().Lexeme == "from" {
+			name := p.advance()
+			selectCase.Name = &name
+		}
+		p.consumeContextual("from", "Expect 'from' after receive.")
+		selectCase.Channel = p.expression()
+	case p.checkContextual("send"):
+		selectCase.Kind = p.advance()
+		selectCase.Value = p.expression()
+		p.consumeContextual("to", "Expect 'to' after sent value.")
+		selectCase.Channel = p.expression()
+	case p.checkContextual("default"):
+		selectCase.Kind = p.advance()
+	default:
+		panic(p.error(p.peek(), "Expect 'receive', 'send' or 'default' case."))
+	}
+
+	p.consume(LEFT_BRACE, "Expect '{' before case body.")
+	selectCase.Body = p.block()
+	return selectCase
+}
+
 func (p *Parser) ifStatement() Stmt {
 	p.consume(LEFT_PAREN, "Expect '(' after 'if'")
 	condition := p.expression()
@@ -171,15 +486,7 @@ func (p *Parser) ifStatement() Stmt {
 
 func (p *Parser) printStatement() Stmt {
 	value := p.expression()
-
-	if !p.check(SEMICOLON) && p.peek().TokenType != EOF {
-		handleRuntimeError(RuntimeError{
-			Token:   p.peek(),
-			Message: "Expect ';' after value",
-		})
-	} else if p.check(SEMICOLON) {
-		p.advance()
-	}
+	p.consumeStatementEnd("Expect ';' after value.")
 
 	return &PrintStatement{
 		Value: value,
@@ -188,23 +495,39 @@ func (p *Parser) printStatement() Stmt {
 
 func (p *Parser) expressionStatement() Stmt {
 	expr := p.expression()
-
-	if !p.check(SEMICOLON) && p.peek().TokenType != EOF {
-		handleRuntimeError(RuntimeError{
-			Token:   p.peek(),
-			Message: "Expect ';' after value",
-		})
-	} else if p.check(SEMICOLON) {
-		p.advance()
-	}
+	p.consumeStatementEnd("Expect ';' after expression.")
 
 	return &ExpressionStatement{
 		Expression: expr,
 	}
 }
 
+// consumeStatementEnd consumes the ';' ending a statement. In expression mode the
+// final statement of the file may leave it out.
+func (p *Parser) consumeStatementEnd(message string) {
+	if p.expressionMode && p.isAtEnd() {
+		return
+	}
+	p.consume(SEMICOLON, message)
+}
+
 func (p *Parser) varDeclaration() Stmt {
+	if p.check(LEFT_BRACKET) || p.check(LEFT_BRACE) {
+		keyword := p.previous()
+		pattern := p.pattern()
+		p.consume(EQUAL, "Expect '=' after destructuring pattern.")
+		initializer := p.expression()
+		p.consume(SEMICOLON, "Expect ';' after variable declaration.")
+
+		return &DestructureStatement{
+			Keyword:     keyword,
+			Pattern:     pattern,
+			Initializer: initializer,
+		}
+	}
+
 	name := p.consume(IDENTIFIER, "Expect variable name.")
+	annotation := p.optionalTypeAnnotation()
 
 	var initializer Expr
 	if p.match(EQUAL) {
@@ -216,22 +539,108 @@ func (p *Parser) varDeclaration() Stmt {
 	return &VarStatement{
 		Name:        name,
 		Initializer: initializer,
+		Type:        annotation,
+	}
+}
+
+func (p *Parser) constDeclaration() Stmt {
+	name := p.consume(IDENTIFIER, "Expect constant name.")
+	annotation := p.optionalTypeAnnotation()
+	p.consume(EQUAL, "Expect '=' after constant name.")
+	initializer := p.expression()
+	p.consume(SEMICOLON, "Expect ';' after constant declaration.")
+
+	return &VarStatement{
+		Name:        name,
+		Initializer: initializer,
+		IsConst:     true,
+		Type:        annotation,
+	}
+}
+
+// optionalTypeAnnotation parses a `: Type` annotation if there is one
+func (p *Parser) optionalTypeAnnotation() *TypeAnnotation {
+	if !p.match(COLON) {
+		return nil
+	}
+	return p.typeAnnotation()
+}
+
+// typeAnnotation parses `Name` or `Name?`. `nil` is a type of its own.
+func (p *Parser) typeAnnotation() *TypeAnnotation {
+	var name Token
+	if p.match(NIL) {
+		name = p.previous()
+	} else {
+		name = p.consume(IDENTIFIER, "Expect type name.")
+	}
+	return &TypeAnnotation{
+		Name:     name,
+		Nullable: p.match(QUESTION),
 	}
 }
 
 func (p *Parser) funDeclaration(kind string) *FunctionStatement {
 	name := p.consume(IDENTIFIER, fmt.Sprintf("Expect %s name.", kind))
+	return p.function(kind, name)
+}
 
+// function parses the parameters and body of a function whose name was already consumed
+func (p *Parser) function(kind string, name Token) *FunctionStatement {
 	p.consume(LEFT_PAREN, fmt.Sprintf("Expect '(' after %s name.", kind))
 
+	parameters, types, defaults, rest := p.parameters()
+	returnType := p.optionalTypeAnnotation()
+
+	p.consume(LEFT_BRACE, fmt.Sprintf("Expect '{' before %s body", kind))
+
+	body := p.block()
+
+	return &FunctionStatement{
+		Body:       body,
+		Name:       name,
+		Params:     parameters,
+		Defaults:   defaults,
+		Rest:       rest,
+		ParamTypes: types,
+		ReturnType: returnType,
+	}
+}
+
+// parameters parses a parameter list up to and including its ')'. Parameters may have
+// type annotations and default values, and the last one may be a `...rest` parameter.
+func (p *Parser) parameters() ([]Token, []*TypeAnnotation, []Expr, *Token) {
 	parameters := make([]Token, 0)
+	types := make([]*TypeAnnotation, 0)
+	defaults := make([]Expr, 0)
+	var rest *Token
 
 	if !p.check(RIGHT_PAREN) {
 		for {
 			if len(parameters) > 255 {
 				p.error(p.peek(), "Cannot have more than 255 parameters.")
 			}
-			parameters = append(parameters, p.consume(IDENTIFIER, "Expect parameter name."))
+
+			if p.match(ELLIPSIS) {
+				name := p.consume(IDENTIFIER, "Expect parameter name after '...'.")
+				rest = &name
+				if p.check(COMMA) {
+					p.error(p.peek(), "A rest parameter must be the last parameter.")
+				}
+				break
+			}
+
+			parameter := p.consume(IDENTIFIER, "Expect parameter name.")
+			types = append(types, p.optionalTypeAnnotation())
+			var defaultValue Expr
+			if p.match(EQUAL) {
+				defaultValue = p.expression()
+			} else if len(defaults) > 0 && defaults[len(defaults)-1] != nil {
+				p.error(parameter, "A parameter without a default can't follow one with a default.")
+			}
+			parameters = append(parameters, parameter)
+			defaults = append(defaults, defaultValue)
+
 			if !p.match(COMMA) {
 				break
 			}
@@ -239,16 +648,7 @@ func (p *Parser) funDeclaration(kind string) *FunctionStatement {
 	}
 
 	p.consume(RIGHT_PAREN, "Expect ')' after parameters.")
-
-	p.consume(LEFT_BRACE, fmt.Sprintf("Expect '{' before %s body", kind))
-
-	body := p.block()
-
-	return &FunctionStatement{
-		Body:   body,
-		Name:   name,
-		Params: parameters,
-	}
+	return parameters, types, defaults, rest
 }
 
 func (p *Parser) classDeclaration(kind string) Stmt {
@@ -263,20 +663,220 @@ func (p *Parser) classDeclaration(kind string) Stmt {
 		}
 	}
 
+	traits := make([]*VariableExpr, 0)
+	if p.checkContextual("with") {
+		p.advance()
+		for {
+			traits = append(traits, &VariableExpr{
+				Name: p.consume(IDENTIFIER, "Expect trait name."),
+			})
+			if !p.match(COMMA) {
+				break
+			}
+		}
+	}
+
 	p.consume(LEFT_BRACE, fmt.Sprintf("Expect '{' after %s name.", kind))
+	p.depth++
 
-	methods := make([]*FunctionStatement, 0)
+	class := &ClassStatement{
+		Name: name,
+		Methods: make([]*FunctionStatement, 0),
+		Fields:  make([]*VarStatement, 0),
+		Superclass: superclass,
+		Traits:     traits,
+		StaticMethods: make([]*FunctionStatement, 0),
+		StaticFields:  make([]*VarStatement, 0),
+		Setters:       make([]*FunctionStatement, 0),
+		PrivateMethods: make([]*FunctionStatement, 0),
+		PrivateFields:  make([]*VarStatement, 0),
+	}
 
 	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
-		methods = append(methods, p.funDeclaration("method"))
+		p.classMember(class)
 	}
 
+	p.depth--
 	p.consume(RIGHT_BRACE, "Expect '}' after class body.")
 
-	return &ClassStatement{
-		Name: name,
-		Methods: methods,
-		Superclass: superclass,
+	return class
+}
+
+// traitDeclaration parses a trait body. It shares the class member syntax, but a trait
+// only provides methods, getters and setters to the classes using it.
+func (p *Parser) traitDeclaration() Stmt {
+	name := p.consume(IDENTIFIER, "Expect trait name.")
+	p.consume(LEFT_BRACE, "Expect '{' after trait name.")
+	p.depth++
+
+	body := &ClassStatement{
+		Name:           name,
+		Methods:        make([]*FunctionStatement, 0),
+		Fields:         make([]*VarStatement, 0),
+		StaticMethods:  make([]*FunctionStatement, 0),
+		StaticFields:   make([]*VarStatement, 0),
+		Setters:        make([]*FunctionStatement, 0),
+		PrivateMethods: make([]*FunctionStatement, 0),
+		PrivateFields:  make([]*VarStatement, 0),
+	}
+
+	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
+		p.classMember(body)
+	}
+
+	p.depth--
+	p.consume(RIGHT_BRACE, "Expect '}' after trait body.")
+
+	for _, method := range append(body.StaticMethods, body.PrivateMethods...) {
+		p.error(method.Name, "A trait can only declare methods, getters and setters.")
+	}
+	for _, field := range append(append(body.Fields, body.StaticFields...), body.PrivateFields...) {
+		p.error(field.Name, "A trait can only declare methods, getters and setters.")
+	}
+
+	return &TraitStatement{
+		Name:    name,
+		Methods: body.Methods,
+		Setters: body.Setters,
+	}
+}
+
+// classMember parses one member of a class body into class, skipping to the next member
+// on a syntax error. `static` is only special when a member name follows it, so it stays
+// usable as a method name.
+func (p *Parser) classMember(class *ClassStatement) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(*ParseError); ok {
+				p.synchronizeClassBody()
+			} else {
+				panic(r)
+			}
+		}
+	}()
+
+	if p.checkContextual("static") && p.peekNext().TokenType == IDENTIFIER {
+		p.advance()
+		if p.peekNext().TokenType == LEFT_PAREN {
+			class.StaticMethods = append(class.StaticMethods, p.funDeclaration("method"))
+			return
+		}
+		class.StaticFields = append(class.StaticFields, p.fieldDeclaration(IDENTIFIER))
+		return
+	}
+
+	if p.check(PRIVATE_IDENTIFIER) {
+		if p.peekNext().TokenType == LEFT_PAREN {
+			name := p.advance()
+			class.PrivateMethods = append(class.PrivateMethods, p.function("method", name))
+			return
+		}
+		class.PrivateFields = append(class.PrivateFields, p.fieldDeclaration(PRIVATE_IDENTIFIER))
+		return
+	}
+
+	if p.checkContextual("set") && p.peekNext().TokenType == IDENTIFIER {
+		p.advance()
+		setter := p.funDeclaration("setter")
+		if len(setter.Params) != 1 || setter.Defaults[0] != nil || setter.Rest != nil {
+			p.error(setter.Name, "A setter must have exactly one parameter.")
+		}
+		class.Setters = append(class.Setters, setter)
+		return
+	}
+
+	if p.check(IDENTIFIER) && (p.peekNext().TokenType == LEFT_BRACE || p.isTypedGetter()) {
+		class.Methods = append(class.Methods, p.getterDeclaration())
+		return
+	}
+
+	if p.check(IDENTIFIER) && p.peekNext().TokenType == COLON {
+		class.Fields = append(class.Fields, p.fieldDeclaration(IDENTIFIER))
+		return
+	}
+
+	if p.match(ASYNC) {
+		method := p.funDeclaration("method")
+		method.IsAsync = true
+		class.Methods = append(class.Methods, method)
+		return
+	}
+
+	class.Methods = append(class.Methods, p.funDeclaration("method"))
+}
+
+// isTypedGetter tells the getter `name: Type { body }` apart from the field `name: Type;`
+func (p *Parser) isTypedGetter() bool {
+	if p.peekNext().TokenType != COLON {
+		return false
+	}
+	next := p.current + 3
+	if next < len(p.tokens) && p.tokens[next].TokenType == QUESTION {
+		next++
+	}
+	return next < len(p.tokens) && p.tokens[next].TokenType == LEFT_BRACE
+}
+
+// getterDeclaration parses `name [: Type] { body }`, a method without a parameter list
+func (p *Parser) getterDeclaration() *FunctionStatement {
+	name := p.consume(IDENTIFIER, "Expect getter name.")
+	returnType := p.optionalTypeAnnotation()
+	p.consume(LEFT_BRACE, "Expect '{' before getter body")
+
+	body := p.block()
+
+	return &FunctionStatement{
+		Body:       body,
+		Name:       name,
+		Params:     make([]Token, 0),
+		Defaults:   make([]Expr, 0),
+		ParamTypes: make([]*TypeAnnotation, 0),
+		ReturnType: returnType,
+		IsGetter:   true,
+	}
+}
+
+// fieldDeclaration parses `name [: Type] [= initializer];` inside a class body
+func (p *Parser) fieldDeclaration(nameType TokenType) *VarStatement {
+	name := p.consume(nameType, "Expect field name.")
+	annotation := p.optionalTypeAnnotation()
+
+	var initializer Expr
+	if p.match(EQUAL) {
+		initializer = p.expression()
+	}
+
+	p.consume(SEMICOLON, "Expect ';' after field declaration.")
+
+	return &VarStatement{
+		Name:        name,
+		Initializer: initializer,
+		Type:        annotation,
+	}
+}
+
+func (p *Parser) enumDeclaration() Stmt {
+	name := p.consume(IDENTIFIER, "Expect enum name.")
+	p.consume(LEFT_BRACE, "Expect '{' after enum name.")
+
+	cases := make([]Token, 0)
+	seen := make(map[string]bool)
+	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
+		enumCase := p.consume(IDENTIFIER, "Expect enum case name.")
+		if seen[enumCase.Lexeme] {
+			p.error(enumCase, fmt.Sprintf("Enum case '%s' is already declared.", enumCase.Lexeme))
+		}
+		seen[enumCase.Lexeme] = true
+		cases = append(cases, enumCase)
+		if !p.match(COMMA) {
+			break
+		}
+	}
+	p.consume(RIGHT_BRACE, "Expect '}' after enum cases.")
+
+	return &EnumStatement{
+		Name:  name,
+		Cases: cases,
 	}
 }
 
@@ -295,14 +895,35 @@ func (p *Parser) declaration() Stmt {
 		return p.varDeclaration()
 	}
 
+	if p.match(CONST) {
+		return p.constDeclaration()
+	}
+
 	if p.match(FUN) {
 		return p.funDeclaration("function")
 	}
 
+	if p.match(ASYNC) {
+		p.consume(FUN, "Expect 'fun' after 'async'.")
+		function := p.funDeclaration("function")
+		function.IsAsync = true
+		return function
+	}
+
 	if p.match(CLASS) {
 		return p.classDeclaration("class")
 	}
 
+	if p.checkContextual("trait") && p.peekNext().TokenType == IDENTIFIER {
+		p.advance()
+		return p.traitDeclaration()
+	}
+
+	if p.checkContextual("enum") && p.peekNext().TokenType == IDENTIFIER {
+		p.advance()
+		return p.enumDeclaration()
+	}
+
 	return p.statement()
 }
 
@@ -311,7 +932,7 @@ func (p *Parser) expression() Expr {
 }
 
 func (p *Parser) assignment() Expr {
-	expr := p.or()
+	expr := p.conditional()
 
 	if p.match(EQUAL) {
 		equal := p.previous()
@@ -331,11 +952,76 @@ func (p *Parser) assignment() Expr {
 				Name: name, 
 				Object: object,
 			}
+		} else if indexExpr, ok := expr.(*IndexExpr); ok {
+			return &IndexSetExpr{
+				Object:  indexExpr.Object,
+				Index:   indexExpr.Index,
+				Value:   value,
+				Bracket: indexExpr.Bracket,
+			}
 		}
 
 		p.error(equal, "Invalid assignment target.")
 	}
 
+	if p.match(PLUS_EQUAL, MINUS_EQUAL, STAR_EQUAL, SLASH_EQUAL, PERCENT_EQUAL) {
+		operator := p.previous()
+		value := p.assignment()
+		return p.compoundAssignment(expr, operator, value, false)
+	}
+
+	return expr
+}
+
+// compoundAssignment builds the node for a compound assignment, increment or decrement
+// of target, reporting targets that can't be assigned to
+func (p *Parser) compoundAssignment(target Expr, operator Token, value Expr, postfix bool) Expr {
+	switch target.(type) {
+	case *VariableExpr, *GetExpression, *IndexExpr:
+	default:
+		p.error(operator, "Invalid assignment target.")
+		return target
+	}
+
+	binary := operator
+	binary.TokenType = compoundOperators[operator.TokenType]
+
+	return &CompoundAssignmentExpr{
+		Target:   target,
+		Operator: binary,
+		Value:    value,
+		Postfix:  postfix,
+	}
+}
+
+func (p *Parser) conditional() Expr {
+	expr := p.coalesce()
+
+	if p.match(QUESTION) {
+		thenBranch := p.expression()
+		p.consume(COLON, "Expect ':' after then branch of conditional expression.")
+		elseBranch := p.conditional()
+		return &ConditionalExpr{
+			Condition:  expr,
+			ThenBranch: thenBranch,
+			ElseBranch: elseBranch,
+		}
+	}
+
+	return expr
+}
+
+func (p *Parser) coalesce() Expr {
+	expr := p.or()
+	for p.match(QUESTION_QUESTION) {
+		operator := p.previous()
+		right := p.or()
+		expr = &LogicalExpr{
+			Operator: operator,
+			Left:     expr,
+			Right:    right,
+		}
+	}
 	return expr
 }
 
@@ -384,9 +1070,90 @@ func (p *Parser) equality() Expr {
 }
 
 func (p *Parser) comparison() Expr {
-	expr := p.term()
+	expr := p.rangeExpression()
 
 	for p.match(LESS, LESS_EQUAL, GREATER, GREATER_EQUAL) {
+		operator := p.previous()
+		right := p.rangeExpression()
+		expr = &BinaryExpr{
+			Left:     expr,
+			Operator: operator,
+			Right:    right,
+		}
+	}
+
+	return expr
+}
+
+// rangeExpression parses `start..end`, which doesn't chain
+func (p *Parser) rangeExpression() Expr {
+	expr := p.bitwiseOr()
+
+	if p.match(DOT_DOT) {
+		operator := p.previous()
+		right := p.bitwiseOr()
+		expr = &BinaryExpr{
+			Left:     expr,
+			Operator: operator,
+			Right:    right,
+		}
+	}
+
+	return expr
+}
+
+func (p *Parser) bitwiseOr() Expr {
+	expr := p.bitwiseXor()
+
+	for p.match(PIPE) {
+		operator := p.previous()
+		right := p.bitwiseXor()
+		expr = &BinaryExpr{
+			Left:     expr,
+			Operator: operator,
+			Right:    right,
+		}
+	}
+
+	return expr
+}
+
+func (p *Parser) bitwiseXor() Expr {
+	expr := p.bitwiseAnd()
+
+	for p.match(CARET) {
+		operator := p.previous()
+		right := p.bitwiseAnd()
+		expr = &BinaryExpr{
+			Left:     expr,
+			Operator: operator,
+			Right:    right,
+		}
+	}
+
+	return expr
+}
+
+func (p *Parser) bitwiseAnd() Expr {
+	expr := p.shift()
+
+	for p.match(AMPERSAND) {
+		operator := p.previous()
+		right := p.shift()
+		expr = &BinaryExpr{
+			Left:     expr,
+			Operator: operator,
+			Right:    right,
+		}
+	}
+
+	return expr
+}
+
+func (p *Parser) shift() Expr {
+	expr := p.term()
+
+	for p.match(LESS_LESS, GREATER_GREATER) {
 		operator := p.previous()
 		right := p.term()
 		expr = &BinaryExpr{
@@ -418,7 +1185,7 @@ func (p *Parser) term() Expr {
 func (p *Parser) factor() Expr {
 	expr := p.unary()
 
-	for p.match(SLASH, STAR) {
+	for p.match(SLASH, STAR, PERCENT, DIV) {
 		operator := p.previous()
 		right := p.unary()
 		expr = &BinaryExpr{
@@ -431,7 +1198,7 @@ func (p *Parser) factor() Expr {
 }
 
 func (p *Parser) unary() Expr {
-	if p.match(BANG, MINUS) {
+	if p.match(BANG, MINUS, TILDE) {
 		operator := p.previous()
 		right := p.unary()
 		expr := &UnaryExpr{
@@ -441,28 +1208,158 @@ func (p *Parser) unary() Expr {
 		return expr
 	}
 
-	return p.call()
+	if p.match(PLUS_PLUS, MINUS_MINUS) {
+		operator := p.previous()
+		target := p.unary()
+		return p.compoundAssignment(target, operator, &LiteralExpr{Value: int64(1)}, false)
+	}
+
+	if p.match(SPAWN) {
+		keyword := p.previous()
+		call, ok := p.call().(*CallExpression)
+		if !ok {
+			panic(p.error(keyword, "Expect a function call after 'spawn'."))
+		}
+		return &SpawnExpr{
+			Keyword: keyword,
+			Call:    call,
+		}
+	}
+
+	if p.match(AWAIT) {
+		return &AwaitExpr{
+			Keyword: p.previous(),
+			Value:   p.unary(),
+		}
+	}
+
+	return p.power()
+}
+
+// power parses '**', which is right-associative and binds tighter than a unary operator
+// on its left, so -2 ** 2 is -(2 ** 2)
+func (p *Parser) power() Expr {
+	expr := p.postfix()
+
+	if p.match(STAR_STAR) {
+		operator := p.previous()
+		right := p.unary()
+		return &BinaryExpr{
+			Left:     expr,
+			Operator: operator,
+			Right:    right,
+		}
+	}
+
+	return expr
+}
+
+func (p *Parser) postfix() Expr {
+	expr := p.call()
+
+	if p.match(PLUS_PLUS, MINUS_MINUS) {
+		operator := p.previous()
+		return p.compoundAssignment(expr, operator, &LiteralExpr{Value: int64(1)}, true)
+	}
+
+	return expr
 }
 
 func (p *Parser) call() Expr {
 	expr := p.primary()
+	optional := false
 	for true {
 		if p.match(LEFT_PAREN) {
 			expr = p.finishCall(expr)
 		} else if p.match(DOT) {
-			name := p.consume(IDENTIFIER, "Expect property name after '.'.")
+			name := p.consumePropertyName("Expect property name after '.'.")
 			expr = &GetExpression{
 				Name: name,
 				Object: expr,
 			}
+		} else if p.match(QUESTION_DOT) {
+			name := p.consumePropertyName("Expect property name after '?.'.")
+			expr = &GetExpression{
+				Name:     name,
+				Object:   expr,
+				Optional: true,
+			}
+			optional = true
+		} else if p.match(LEFT_BRACKET) {
+			index := p.expression()
+			expr = &IndexExpr{
+				Object:  expr,
+				Index:   index,
+				Bracket: p.consume(RIGHT_BRACKET, "Expect ']' after index."),
+			}
 		} else {
 			break 
 		}
 	}
+
+	if optional {
+		return &OptionalChainExpr{
+			Expression: expr,
+		}
+	}
 	return expr
 }
 
+func (p *Parser) listLiteral() Expr {
+	elements := make([]Expr, 0)
+	if !p.check(RIGHT_BRACKET) {
+		for {
+			elements = append(elements, p.expression())
+			if !p.match(COMMA) {
+				break
+			}
+		}
+	}
+	p.consume(RIGHT_BRACKET, "Expect ']' after list elements.")
+
+	return &ListExpr{
+		Elements: elements,
+	}
+}
+
+func (p *Parser) mapLiteral() Expr {
+	keys := make([]Expr, 0)
+	values := make([]Expr, 0)
+	if !p.check(RIGHT_BRACE) {
+		for {
+			keys = append(keys, p.expression())
+			p.consume(COLON, "Expect ':' after map key.")
+			values = append(values, p.expression())
+			if !p.match(COMMA) {
+				break
+			}
+		}
+	}
+	p.consume(RIGHT_BRACE, "Expect '}' after map entries.")
+
+	return &MapExpr{
+		Keys:   keys,
+		Values: values,
+	}
+}
+
+// consumePropertyName consumes a public or `#private` property name
+func (p *Parser) consumePropertyName(message string) Token {
+	if p.match(PRIVATE_IDENTIFIER) {
+		return p.previous()
+	}
+	return p.consume(IDENTIFIER, message)
+}
+
 func (p *Parser) primary() Expr {
+	if p.match(LEFT_BRACKET) {
+		return p.listLiteral()
+	}
+
+	if p.match(LEFT_BRACE) {
+		return p.mapLiteral()
+	}
+
 	if p.match(FALSE) {
 		return &LiteralExpr{
 			Value: false,
@@ -493,6 +1390,10 @@ func (p *Parser) primary() Expr {
 		}
 	}
 
+	if p.match(INTERPOLATION) {
+		return p.interpolation()
+	}
+
 	if p.match(LEFT_PAREN) {
 		expr := p.expression()
 		p.consume(RIGHT_PAREN, "Expect ')' after expression.")
@@ -507,6 +1408,15 @@ func (p *Parser) primary() Expr {
 		}
 	}
 
+	if p.match(MATCH) {
+		keyword, subject, arms := p.matchArms(false)
+		return &MatchExpr{
+			Keyword: keyword,
+			Subject: subject,
+			Arms:    arms,
+		}
+	}
+
 	if p.match(SUPER) {
 		keyword := p.previous()
 		p.consume(DOT, "Expect '.' after 'super'.")
@@ -520,6 +1430,26 @@ func (p *Parser) primary() Expr {
 	panic(p.error(p.peek(), "Expect expression."))
 }
 
+// interpolation parses the remaining parts of a string literal whose first part was just matched
+func (p *Parser) interpolation() Expr {
+	parts := make([]Expr, 0)
+	for {
+		parts = append(parts, &LiteralExpr{Value: p.previous().Literal})
+		parts = append(parts, p.expression())
+		p.consume(INTERPOLATION_END, "Expect '}' after interpolated expression.")
+		if !p.match(INTERPOLATION) {
+			break
+		}
+	}
+
+	end := p.consume(STRING, "Expect end of string interpolation.")
+	parts = append(parts, &LiteralExpr{Value: end.Literal})
+
+	return &InterpolationExpr{
+		Parts: parts,
+	}
+}
+
 func (p *Parser) match(tokens ...TokenType) bool {
 	for _, token := range tokens {
 		if p.check(token) {
@@ -530,6 +1460,20 @@ func (p *Parser) match(tokens ...TokenType) bool {
 	return false
 }
 
+// checkContextual reports whether the next token is the identifier word, for words that
+// are keywords only in some positions
+func (p *Parser) checkContextual(word string) bool {
+	return p.check(IDENTIFIER) && p.peek().Lexeme == word
+}
+
+func (p *Parser) consumeContextual(word string, message string) Token {
+	if p.checkContextual(word) {
+		return p.advance()
+	}
+
+	panic(p.error(p.peek(), message))
+}
+
 func (p *Parser) check(token TokenType) bool {
 	if p.isAtEnd() {
 		return false
@@ -548,6 +1492,13 @@ func (p *Parser) peek() Token {
 	return p.tokens[p.current]
 }
 
+func (p *Parser) peekNext() Token {
+	if p.current+1 >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.current+1]
+}
+
 func (p *Parser) previous() Token {
 	return p.tokens[p.current-1]
 }
@@ -568,6 +1519,16 @@ func (p *Parser) consume(TokenType TokenType, message string) Token {
 }
 
 func (p *Parser) synchronize() {
+	// A statement that starts right at the error token (typically after a missing ';')
+	// is parsed as usual, and a '}' closing the enclosing block is left for block()
+	switch p.peek().TokenType {
+	case CLASS, FUN, ASYNC, VAR, CONST, FOR, IF, MATCH, WHILE, PRINT, RETURN, YIELD, SELECT:
+		return
+	case RIGHT_BRACE:
+		if p.depth > 0 {
+			return
+		}
+	}
 	p.advance()
 
 	for !p.isAtEnd() {
@@ -576,23 +1537,60 @@ func (p *Parser) synchronize() {
 		}
 
 		switch p.peek().TokenType {
-		case CLASS, FUN, VAR, FOR, IF, WHILE, PRINT, RETURN:
+		case CLASS, FUN, ASYNC, VAR, CONST, FOR, IF, MATCH, WHILE, PRINT, RETURN, YIELD, SELECT:
 			return
+		case RIGHT_BRACE:
+			if p.depth > 0 {
+				return
+			}
 		}
 
 		p.advance()
 	}
 }
 
+// synchronizeClassBody skips the rest of a malformed class member, stopping after its
+// body or before the '}' that closes the class
+func (p *Parser) synchronizeClassBody() {
+	nesting := 0
+	for !p.isAtEnd() {
+		switch p.peek().TokenType {
+		case LEFT_BRACE:
+			nesting++
+		case RIGHT_BRACE:
+			if nesting == 0 {
+				return
+			}
+			nesting--
+			if nesting == 0 {
+				p.advance()
+				return
+			}
+		}
+		p.advance()
+	}
+}
+
 func (p *Parser) finishCall(callee Expr) Expr {
 	arguments := make([]Expr, 0)
+	names := make([]Token, 0)
+	namedArguments := make([]Expr, 0)
 
 	if !p.check(RIGHT_PAREN) {
 		for {
-			if len(arguments) > 255 {
+			if len(arguments)+len(namedArguments) > 255 {
 				p.error(p.peek(), "Can't have more than 255 arguments.")
 			}
-			arguments = append(arguments, p.expression())
+			if p.check(IDENTIFIER) && p.peekNext().TokenType == COLON {
+				names = append(names, p.advance())
+				p.advance()
+				namedArguments = append(namedArguments, p.expression())
+			} else {
+				if len(namedArguments) > 0 {
+					p.error(p.peek(), "Positional arguments must come before named arguments.")
+				}
+				arguments = append(arguments, p.expression())
+			}
 			if !p.match(COMMA) {
 				break
 			}
@@ -602,9 +1600,11 @@ func (p *Parser) finishCall(callee Expr) Expr {
 	paren := p.consume(RIGHT_PAREN, "Expect ')' after arguments.")
 
 	return &CallExpression{
-		Callee:      callee,
-		Arguments:   arguments,
-		Parenthesis: paren,
+		Callee:         callee,
+		Arguments:      arguments,
+		Names:          names,
+		NamedArguments: namedArguments,
+		Parenthesis:    paren,
 	}
 }
 
@@ -612,16 +1612,6 @@ func (p *Parser) parse() []Stmt {
 	statements := make([]Stmt, 0)
 
 	for !p.isAtEnd() {
-		defer func() {
-			if r := recover(); r != nil {
-				if _, ok := r.(*ParseError); ok {
-					p.synchronize()
-				} else {
-					panic(r)
-				}
-			}
-		}()
-
 		declaration := p.declaration()
 		if declaration != nil {
 			statements = append(statements, declaration)
@@ -631,6 +1621,18 @@ func (p *Parser) parse() []Stmt {
 	return statements
 }
 
+// abort reports that the error limit was reached and skips to the end of the tokens
+// so every parsing loop unwinds
+func (p *Parser) abort(token Token) {
+	report(Diagnostic{
+		Severity: SEVERITY_ERROR,
+		Code:     CODE_PARSE_ERROR,
+		Location: Location{Line: token.Line, Column: token.Column},
+		Message:  fmt.Sprintf("Too many errors, stopping after %d.", maxParseErrors),
+	}, "")
+	p.current = len(p.tokens) - 1
+}
+
 type ParseError struct {
 	Token   Token
 	Message string
@@ -641,10 +1643,27 @@ func (e *ParseError) Error() string {
 }
 
 func (p *Parser) error(token Token, message string) *ParseError {
+	p.errorCount++
+	if p.errorCount > maxParseErrors {
+		return &ParseError{
+			Token:   token,
+			Message: message,
+		}
+	}
+	if p.errorCount == maxParseErrors {
+		defer p.abort(token)
+	}
+
+	diagnostic := Diagnostic{
+		Severity: SEVERITY_ERROR,
+		Code:     CODE_PARSE_ERROR,
+		Location: Location{Line: token.Line, Column: token.Column},
+		Message:  message,
+	}
 	if token.TokenType == EOF {
-		report(token.Line, " at end", message)
+		report(diagnostic, " at end")
 	} else {
-		report(token.Line, fmt.Sprintf(" at '%s'", token.Lexeme), message)
+		report(diagnostic, fmt.Sprintf(" at '%s'", token.Lexeme))
 	}
 
 	return &ParseError{
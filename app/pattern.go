@@ -0,0 +1,99 @@
+package main
+
+// MatchArm is one `pattern [if guard] => ...` arm of a match. Body is set in match
+// statements and Value in match expressions.
+type MatchArm struct {
+	Pattern Pattern
+	Guard   Expr
+	Body    Stmt
+	Value   Expr
+}
+
+// Pattern is the left-hand side of a match arm
+type Pattern interface {
+	pattern()
+}
+
+// LiteralPattern matches a value equal to a number, string, boolean or nil literal
+type LiteralPattern struct {
+	Token Token
+	Value interface{}
+}
+
+// WildcardPattern is `_`, which matches anything
+type WildcardPattern struct {
+	Token Token
+}
+
+// ValuePattern is a dotted name such as `Color.Red`, matching a value equal to what it names
+type ValuePattern struct {
+	Value Expr
+}
+
+// BindingPattern is a bare name, which matches anything and binds it to the name
+type BindingPattern struct {
+	Name Token
+}
+
+// ListPattern is `[first, second, ...rest]`. Without Rest it only matches lists of
+// exactly as many elements, with it the remaining elements are bound as a new list.
+type ListPattern struct {
+	Bracket  Token
+	Elements []Pattern
+	Rest     *Token
+}
+
+// MapPattern is `{"key": pattern, ...}`, matching maps that have all of the keys with
+// matching values. Other keys are ignored.
+type MapPattern struct {
+	Brace  Token
+	Keys   []*LiteralPattern
+	Values []Pattern
+}
+
+// ClassPattern is `Point(x, y)` or `Point(x: px)`, matching instances of the class or of
+// a subclass and their fields. Positional patterns stand for the fields named like the
+// parameters of the class's initializer, named ones for the field they name.
+type ClassPattern struct {
+	Class      Expr
+	Paren      Token
+	Positional []Pattern
+	Names      []Token
+	Named      []Pattern
+}
+
+func (*LiteralPattern) pattern()  {}
+func (*WildcardPattern) pattern() {}
+func (*ValuePattern) pattern()    {}
+func (*BindingPattern) pattern()  {}
+func (*ListPattern) pattern()     {}
+func (*MapPattern) pattern()      {}
+func (*ClassPattern) pattern()    {}
+
+// patternBindings returns the names pattern binds, in order
+func patternBindings(pattern Pattern) []Token {
+	names := make([]Token, 0)
+	switch pattern := pattern.(type) {
+	case *BindingPattern:
+		names = append(names, pattern.Name)
+	case *ListPattern:
+		for _, element := range pattern.Elements {
+			names = append(names, patternBindings(element)...)
+		}
+		if pattern.Rest != nil && pattern.Rest.Lexeme != "_" {
+			names = append(names, *pattern.Rest)
+		}
+	case *MapPattern:
+		for _, value := range pattern.Values {
+			names = append(names, patternBindings(value)...)
+		}
+	case *ClassPattern:
+		for _, positional := range pattern.Positional {
+			names = append(names, patternBindings(positional)...)
+		}
+		for _, named := range pattern.Named {
+			names = append(names, patternBindings(named)...)
+		}
+	}
+	return names
+}
@@ -1,18 +1,27 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type Resolver struct {
 	Interpreter     *Interpreter
-	Scopes          []map[string]bool // string for var/func name and bool for wether it's been defined or not. Initially we only declare and only after a safe check we define
+	Scopes          []map[string]*binding
 	CurrentFunction FunctionType
+	// CurrentDeclaration is the innermost function being resolved, marked as a generator
+	// when a `yield` is found in it
+	CurrentDeclaration *FunctionStatement
 	CurrentClass ClassType
+	// PrivateNames holds the `#name` members declared by each enclosing class body,
+	// innermost last
+	PrivateNames []map[string]bool
 }
 
 func NewResolver(intepreter *Interpreter) *Resolver {
 	r :=  &Resolver{
 		Interpreter:     intepreter,
-		Scopes:          make([]map[string]bool, 0),
+		Scopes:          make([]map[string]*binding, 0),
 		CurrentFunction: FUNCTION_NONE,
 		CurrentClass: CLASS_NONE,
 	}
@@ -20,8 +29,19 @@ func NewResolver(intepreter *Interpreter) *Resolver {
 	return r
 }
 
+// binding is what the resolver knows about a name declared in a scope
+type binding struct {
+	// defined is false between the declaration and the end of the initializer.
+	// Initially we only declare and only after a safe check we define.
+	defined  bool
+	constant bool
+	// enum is the declaration of the enum bound to the name, used to check that a match
+	// covers all of its cases
+	enum *EnumStatement
+}
+
 func (r *Resolver) beginScope() {
-	r.Scopes = append(r.Scopes, make(map[string]bool))
+	r.Scopes = append(r.Scopes, make(map[string]*binding))
 }
 
 func (r *Resolver) resolve(statements []Stmt) {
@@ -58,6 +78,9 @@ func (r *Resolver) visitVarStmt(stmt *VarStatement) interface{} {
 		r.resolveExpression(stmt.Initializer)
 	}
 	r.define(stmt.Name)
+	if stmt.IsConst {
+		r.Scopes[len(r.Scopes)-1][stmt.Name.Lexeme].constant = true
+	}
 	return nil
 }
 
@@ -73,7 +96,7 @@ func (r *Resolver) declare(name Token) {
 	}
 
     if len(r.Scopes) > 1 {
-        scope[name.Lexeme] = false
+        scope[name.Lexeme] = &binding{}
     }
 }
 
@@ -81,12 +104,19 @@ func (r *Resolver) define(name Token) {
     if len(r.Scopes) == 0 {
         return
     }
-    r.Scopes[len(r.Scopes)-1][name.Lexeme] = true
+    scope := r.Scopes[len(r.Scopes)-1]
+    // Locals can't be declared twice at all, see declare
+    if declared, exists := scope[name.Lexeme]; exists && declared.constant && len(r.Scopes) == 1 {
+        r.error(name, fmt.Sprintf("Can't redeclare constant '%s'.", name.Lexeme))
+        return
+    }
+    // Redefining a global forgets what was known about its previous value
+    scope[name.Lexeme] = &binding{defined: true}
 }
 
 func (r *Resolver) visitVariableExpr(expr *VariableExpr) interface{} {
     if len(r.Scopes) > 1 {
-        if initialized, exists := r.Scopes[len(r.Scopes)-1][expr.Name.Lexeme]; exists && !initialized {
+        if declared, exists := r.Scopes[len(r.Scopes)-1][expr.Name.Lexeme]; exists && !declared.defined {
 			r.error(expr.Name, "Can't read local variable in its own initializer.")
         }
     }
@@ -94,8 +124,10 @@ func (r *Resolver) visitVariableExpr(expr *VariableExpr) interface{} {
     return nil
 }
 
+// resolveLocal records how many scopes up name is declared. Globals are left
+// unresolved and looked up dynamically.
 func (r *Resolver) resolveLocal(expr Expr, name Token) {
-	for i := len(r.Scopes) - 1; i >= 0; i-- {
+	for i := len(r.Scopes) - 1; i > 0; i-- {
 		if _, exists := r.Scopes[i][name.Lexeme]; exists {
 			r.Interpreter.resolve(expr, len(r.Scopes)-1-i)
 			return
@@ -103,23 +135,139 @@ func (r *Resolver) resolveLocal(expr Expr, name Token) {
 	}
 }
 
+// resolveAssignment resolves the variable assigned by expr, which can't be a local
+// constant. Assignments to global constants fail at runtime instead.
+func (r *Resolver) resolveAssignment(expr Expr, name Token) {
+	for i := len(r.Scopes) - 1; i > 0; i-- {
+		if declared, exists := r.Scopes[i][name.Lexeme]; exists {
+			if declared.constant {
+				r.error(name, fmt.Sprintf("Can't assign to constant '%s'.", name.Lexeme))
+			}
+			break
+		}
+	}
+	r.resolveLocal(expr, name)
+}
+
 func (r *Resolver) visitAssignmentExpr(expr *AssignmentExpr) interface{} {
 	r.resolveExpression(expr.Value)
-	r.resolveLocal(expr, expr.Name)
+	r.resolveAssignment(expr, expr.Name)
+	return nil
+}
+
+func (r *Resolver) visitCompoundAssignmentExpr(expr *CompoundAssignmentExpr) interface{} {
+	r.resolveExpression(expr.Value)
+	switch target := expr.Target.(type) {
+	case *VariableExpr:
+		r.resolveAssignment(expr, target.Name)
+	case *GetExpression:
+		r.resolveExpression(target.Object)
+		r.checkPrivateAccess(target.Object, target.Name)
+	case *IndexExpr:
+		r.resolveExpression(target.Object)
+		r.resolveExpression(target.Index)
+	}
 	return nil
 }
 
 func (r *Resolver) visitGetExpr(expr *GetExpression) interface{} {
 	r.resolveExpression(expr.Object)
+	r.checkPrivateAccess(expr.Object, expr.Name)
 	return nil 
 }
 
 func (r *Resolver) visitSetExpr(expr *SetExpression) interface{} {
 	r.resolveExpression(expr.Object)
 	r.resolveExpression(expr.Value)
+	r.checkPrivateAccess(expr.Object, expr.Name)
 	return nil 
 }
 
+func (r *Resolver) visitIndexExpr(expr *IndexExpr) interface{} {
+	r.resolveExpression(expr.Object)
+	r.resolveExpression(expr.Index)
+	return nil
+}
+
+func (r *Resolver) visitIndexSetExpr(expr *IndexSetExpr) interface{} {
+	r.resolveExpression(expr.Object)
+	r.resolveExpression(expr.Index)
+	r.resolveExpression(expr.Value)
+	return nil
+}
+
+func (r *Resolver) visitListExpr(expr *ListExpr) interface{} {
+	for _, element := range expr.Elements {
+		r.resolveExpression(element)
+	}
+	return nil
+}
+
+func (r *Resolver) visitMapExpr(expr *MapExpr) interface{} {
+	for index, key := range expr.Keys {
+		r.resolveExpression(key)
+		r.resolveExpression(expr.Values[index])
+	}
+	return nil
+}
+
+func (r *Resolver) visitSpawnExpr(expr *SpawnExpr) interface{} {
+	r.resolveExpression(expr.Call)
+	return nil
+}
+
+func (r *Resolver) visitAwaitExpr(expr *AwaitExpr) interface{} {
+	r.resolveExpression(expr.Value)
+	return nil
+}
+
+// checkOperatorMethod makes sure a special method such as __add__ accepts the arguments
+// the interpreter calls it with
+func (r *Resolver) checkOperatorMethod(method *FunctionStatement) {
+	count, special := operatorMethodArguments[method.Name.Lexeme]
+	if !special {
+		return
+	}
+
+	required := 0
+	for _, defaultValue := range method.Defaults {
+		if defaultValue == nil {
+			required++
+		}
+	}
+	if count >= required && (method.Rest != nil || count <= len(method.Params)) {
+		return
+	}
+
+	noun := "parameters"
+	if count == 1 {
+		noun = "parameter"
+	}
+	r.error(method.Name, fmt.Sprintf("Method %s must take %d %s.", method.Name.Lexeme, count, noun))
+}
+
+func (r *Resolver) declarePrivate(names map[string]bool, name Token) {
+	if names[name.Lexeme] {
+		r.error(name, fmt.Sprintf("Private member %s is already declared in this class.", name.Lexeme))
+	}
+	names[name.Lexeme] = true
+}
+
+// checkPrivateAccess makes sure a `#name` member is only reached through `this`, inside
+// the class body that declares it
+func (r *Resolver) checkPrivateAccess(object Expr, name Token) {
+	if name.TokenType != PRIVATE_IDENTIFIER {
+		return
+	}
+	if _, isThis := object.(*ThisExpr); !isThis || len(r.PrivateNames) == 0 {
+		r.error(name, fmt.Sprintf("Private member %s can only be accessed through 'this' inside its class.", name.Lexeme))
+		return
+	}
+	if !r.PrivateNames[len(r.PrivateNames)-1][name.Lexeme] {
+		r.error(name, fmt.Sprintf("Private member %s is not declared in this class.", name.Lexeme))
+	}
+}
+
 func (r *Resolver) visitFunctionStmt(stmt *FunctionStatement) interface{} {
 	r.declare(stmt.Name)
 	r.define(stmt.Name)
@@ -129,18 +277,29 @@ func (r *Resolver) visitFunctionStmt(stmt *FunctionStatement) interface{} {
 }
 
 func (r *Resolver) resolveFunction(function *FunctionStatement, functionType FunctionType) {
-    enclosingFunction := r.CurrentFunction
-    r.CurrentFunction = functionType
+    enclosingFunction, enclosingDeclaration := r.CurrentFunction, r.CurrentDeclaration
+    r.CurrentFunction, r.CurrentDeclaration = functionType, function
+
+    if function.IsAsync && functionType == FUNCTION_INITIALIZER {
+        r.error(function.Name, "An initializer can't be async.")
+    }
 
     r.beginScope()
-    for _, param := range function.Params {
+    for index, param := range function.Params {
+        if function.Defaults[index] != nil {
+            r.resolveExpression(function.Defaults[index])
+        }
         r.declare(param)
         r.define(param)
     }
+    if function.Rest != nil {
+        r.declare(*function.Rest)
+        r.define(*function.Rest)
+    }
     r.resolve(function.Body)
     r.endScope()
     
-    r.CurrentFunction = enclosingFunction
+    r.CurrentFunction, r.CurrentDeclaration = enclosingFunction, enclosingDeclaration
 }
 
 func (r *Resolver) visitExpressionStmt(stmt *ExpressionStatement) interface{} {
@@ -183,6 +342,192 @@ func (r *Resolver) visitWhileStmt(stmt *WhileStatement) interface{} {
 	return nil
 }
 
+func (r *Resolver) visitForInStmt(stmt *ForInStatement) interface{} {
+	r.resolveExpression(stmt.Iterable)
+
+	r.beginScope()
+	r.declare(stmt.Name)
+	r.define(stmt.Name)
+	r.resolveStatement(stmt.Body)
+	r.endScope()
+	return nil
+}
+
+func (r *Resolver) visitYieldStmt(stmt *YieldStatement) interface{} {
+	switch r.CurrentFunction {
+	case FUNCTION_NONE:
+		r.error(stmt.Keyword, "Can't yield from top-level code.")
+	case FUNCTION_INITIALIZER:
+		r.error(stmt.Keyword, "Can't yield from an initializer.")
+	default:
+		if r.CurrentDeclaration.IsAsync {
+			r.error(stmt.Keyword, "Can't yield from an async function.")
+		}
+		r.CurrentDeclaration.IsGenerator = true
+	}
+
+	if stmt.Value != nil {
+		r.resolveExpression(stmt.Value)
+	}
+	return nil
+}
+
+func (r *Resolver) visitSelectStmt(stmt *SelectStatement) interface{} {
+	for _, selectCase := range stmt.Cases {
+		if selectCase.Channel != nil {
+			r.resolveExpression(selectCase.Channel)
+		}
+		if selectCase.Value != nil {
+			r.resolveExpression(selectCase.Value)
+		}
+
+		r.beginScope()
+		if selectCase.Name != nil {
+			r.declare(*selectCase.Name)
+			r.define(*selectCase.Name)
+		}
+		r.resolve(selectCase.Body)
+		r.endScope()
+	}
+	return nil
+}
+
+func (r *Resolver) visitEnumStmt(stmt *EnumStatement) interface{} {
+	r.declare(stmt.Name)
+	r.define(stmt.Name)
+	r.Scopes[len(r.Scopes)-1][stmt.Name.Lexeme].enum = stmt
+	return nil
+}
+
+func (r *Resolver) visitMatchStmt(stmt *MatchStatement) interface{} {
+	r.resolveExpression(stmt.Subject)
+	for _, arm := range stmt.Arms {
+		r.beginScope()
+		r.resolvePattern(arm.Pattern)
+		for _, name := range patternBindings(arm.Pattern) {
+			r.declare(name)
+			r.define(name)
+		}
+		if arm.Guard != nil {
+			r.resolveExpression(arm.Guard)
+		}
+		r.resolveStatement(arm.Body)
+		r.endScope()
+	}
+	r.checkExhaustive(stmt.Keyword, stmt.Arms)
+	return nil
+}
+
+func (r *Resolver) visitMatchExpr(expr *MatchExpr) interface{} {
+	r.resolveExpression(expr.Subject)
+	for _, arm := range expr.Arms {
+		r.beginScope()
+		r.resolvePattern(arm.Pattern)
+		for _, name := range patternBindings(arm.Pattern) {
+			r.declare(name)
+			r.define(name)
+		}
+		if arm.Guard != nil {
+			r.resolveExpression(arm.Guard)
+		}
+		r.resolveExpression(arm.Value)
+		r.endScope()
+	}
+	r.checkExhaustive(expr.Keyword, expr.Arms)
+	return nil
+}
+
+func (r *Resolver) visitDestructureStmt(stmt *DestructureStatement) interface{} {
+	names := patternBindings(stmt.Pattern)
+	for _, name := range names {
+		r.declare(name)
+	}
+	r.resolveExpression(stmt.Initializer)
+	r.resolvePattern(stmt.Pattern)
+	for _, name := range names {
+		r.define(name)
+	}
+	return nil
+}
+
+// resolvePattern resolves the expressions inside pattern. The names it binds are
+// declared by the caller.
+func (r *Resolver) resolvePattern(pattern Pattern) {
+	switch pattern := pattern.(type) {
+	case *ValuePattern:
+		r.resolveExpression(pattern.Value)
+	case *ListPattern:
+		for _, element := range pattern.Elements {
+			r.resolvePattern(element)
+		}
+	case *MapPattern:
+		for _, value := range pattern.Values {
+			r.resolvePattern(value)
+		}
+	case *ClassPattern:
+		r.resolveExpression(pattern.Class)
+		for _, positional := range pattern.Positional {
+			r.resolvePattern(positional)
+		}
+		for _, named := range pattern.Named {
+			r.resolvePattern(named)
+		}
+	}
+}
+
+// checkExhaustive warns about a match over the cases of an enum that leaves some of them
+// out. Arms with a guard don't count as covering their case.
+func (r *Resolver) checkExhaustive(keyword Token, arms []*MatchArm) {
+	var enum *EnumStatement
+	covered := make(map[string]bool)
+	for _, arm := range arms {
+		switch pattern := arm.Pattern.(type) {
+		case *WildcardPattern, *BindingPattern:
+			if arm.Guard == nil {
+				return
+			}
+		case *ValuePattern:
+			get, ok := pattern.Value.(*GetExpression)
+			if !ok {
+				continue
+			}
+			object, ok := get.Object.(*VariableExpr)
+			if !ok {
+				continue
+			}
+			if armEnum := r.enumNamed(object.Name); armEnum != nil && (enum == nil || armEnum == enum) {
+				enum = armEnum
+				if arm.Guard == nil {
+					covered[get.Name.Lexeme] = true
+				}
+			}
+		}
+	}
+	if enum == nil {
+		return
+	}
+
+	missing := make([]string, 0)
+	for _, enumCase := range enum.Cases {
+		if !covered[enumCase.Lexeme] {
+			missing = append(missing, enumCase.Lexeme)
+		}
+	}
+	if len(missing) > 0 {
+		r.warning(keyword, fmt.Sprintf("Match over %s isn't exhaustive, missing %s.", enum.Name.Lexeme, strings.Join(missing, ", ")))
+	}
+}
+
+// enumNamed returns the enum declaration name refers to, or nil when it doesn't name one
+func (r *Resolver) enumNamed(name Token) *EnumStatement {
+	for i := len(r.Scopes) - 1; i >= 0; i-- {
+		if declared, exists := r.Scopes[i][name.Lexeme]; exists {
+			return declared.enum
+		}
+	}
+	return nil
+}
+
 func (r *Resolver) visitClassStmt(stmt *ClassStatement) interface{} {
 	enclosingClass := r.CurrentClass
 	r.CurrentClass = CLASS_CLASS
@@ -191,7 +536,7 @@ func (r *Resolver) visitClassStmt(stmt *ClassStatement) interface{} {
 	r.define(stmt.Name)
 
 	if stmt.Superclass != nil {
-		if stmt.Name == stmt.Superclass.Name {
+		if stmt.Name.Lexeme == stmt.Superclass.Name.Lexeme {
 			r.error(stmt.Superclass.Name, "A class can't inherit from itself.")
 		}
 	}
@@ -201,22 +546,57 @@ func (r *Resolver) visitClassStmt(stmt *ClassStatement) interface{} {
 		r.resolveExpression(stmt.Superclass)
 	}
 
+	for _, trait := range stmt.Traits {
+		r.resolveExpression(trait)
+	}
+
 	if stmt.Superclass != nil {
 		r.beginScope()
-		r.Scopes[len(r.Scopes) -1]["super"] = true 
+		r.Scopes[len(r.Scopes) -1]["super"] = &binding{defined: true}
 	}
 
 	r.beginScope()
-	r.Scopes[len(r.Scopes) - 1]["this"] = true   
+	r.Scopes[len(r.Scopes) - 1]["this"] = &binding{defined: true}
+
+	privateNames := make(map[string]bool)
+	for _, method := range stmt.PrivateMethods {
+		r.declarePrivate(privateNames, method.Name)
+	}
+	for _, field := range stmt.PrivateFields {
+		r.declarePrivate(privateNames, field.Name)
+	}
+	r.PrivateNames = append(r.PrivateNames, privateNames)
 	
 	for _, method := range stmt.Methods {
 		declaration := FUNCTION_METHOD 
 		if method.Name.Lexeme == "init" {
 			declaration = FUNCTION_INITIALIZER
 		}
+		r.checkOperatorMethod(method)
 		r.resolveFunction(method, declaration)
 	}
 
+	for _, method := range stmt.StaticMethods {
+		r.resolveFunction(method, FUNCTION_METHOD)
+	}
+
+	for _, setter := range stmt.Setters {
+		r.resolveFunction(setter, FUNCTION_METHOD)
+	}
+
+	for _, method := range stmt.PrivateMethods {
+		r.resolveFunction(method, FUNCTION_METHOD)
+	}
+
+	for _, fields := range [][]*VarStatement{stmt.Fields, stmt.StaticFields, stmt.PrivateFields} {
+		for _, field := range fields {
+			if field.Initializer != nil {
+				r.resolveExpression(field.Initializer)
+			}
+		}
+	}
+
+	r.PrivateNames = r.PrivateNames[:len(r.PrivateNames)-1]
 	r.endScope()
 	if stmt.Superclass != nil { 
 		r.endScope()
@@ -226,6 +606,42 @@ func (r *Resolver) visitClassStmt(stmt *ClassStatement) interface{} {
 	return nil 
 }
 
+// visitTraitStmt resolves trait methods as if they were declared in a subclass. `super`
+// is bound when the trait is mixed in, to the superclass of the class using it.
+func (r *Resolver) visitTraitStmt(stmt *TraitStatement) interface{} {
+	enclosingClass := r.CurrentClass
+	r.CurrentClass = CLASS_TRAIT
+
+	r.declare(stmt.Name)
+	r.define(stmt.Name)
+
+	r.beginScope()
+	r.Scopes[len(r.Scopes)-1]["super"] = &binding{defined: true}
+	r.beginScope()
+	r.Scopes[len(r.Scopes)-1]["this"] = &binding{defined: true}
+	r.PrivateNames = append(r.PrivateNames, make(map[string]bool))
+
+	for _, method := range stmt.Methods {
+		declaration := FUNCTION_METHOD
+		if method.Name.Lexeme == "init" {
+			declaration = FUNCTION_INITIALIZER
+		}
+		r.checkOperatorMethod(method)
+		r.resolveFunction(method, declaration)
+	}
+
+	for _, setter := range stmt.Setters {
+		r.resolveFunction(setter, FUNCTION_METHOD)
+	}
+
+	r.PrivateNames = r.PrivateNames[:len(r.PrivateNames)-1]
+	r.endScope()
+	r.endScope()
+
+	r.CurrentClass = enclosingClass
+	return nil
+}
+
 func (r *Resolver) visitBinaryExpr(expr *BinaryExpr) interface{} {
 	r.resolveExpression(expr.Left)
 	r.resolveExpression(expr.Right)
@@ -237,6 +653,9 @@ func (r *Resolver) visitCallExpr(expr *CallExpression) interface{} {
 	for _, expression := range expr.Arguments {
 		r.resolveExpression(expression)
 	}
+	for _, expression := range expr.NamedArguments {
+		r.resolveExpression(expression)
+	}
 	return nil
 }
 
@@ -278,6 +697,25 @@ func (r *Resolver) visitSuperExpr(expr *SuperExpr) interface{} {
 	return nil 
 }
 
+func (r *Resolver) visitInterpolationExpr(expr *InterpolationExpr) interface{} {
+	for _, part := range expr.Parts {
+		r.resolveExpression(part)
+	}
+	return nil
+}
+
+func (r *Resolver) visitConditionalExpr(expr *ConditionalExpr) interface{} {
+	r.resolveExpression(expr.Condition)
+	r.resolveExpression(expr.ThenBranch)
+	r.resolveExpression(expr.ElseBranch)
+	return nil
+}
+
+func (r *Resolver) visitOptionalChainExpr(expr *OptionalChainExpr) interface{} {
+	r.resolveExpression(expr.Expression)
+	return nil
+}
+
 type FunctionType int
 
 const (
@@ -293,8 +731,23 @@ const (
 	CLASS_NONE ClassType = iota 
 	CLASS_CLASS 
 	CLASS_SUBCLASS
+	CLASS_TRAIT
 )
 
+func (r *Resolver) warning(token Token, message string) {
+	report(Diagnostic{
+		Severity: SEVERITY_WARNING,
+		Code:     CODE_MATCH_WARNING,
+		Location: Location{Line: token.Line, Column: token.Column},
+		Message:  message,
+	}, fmt.Sprintf(" at '%s'", token.Lexeme))
+}
+
 func (r *Resolver) error(token Token, message string) {
-    report(token.Line, fmt.Sprintf(" at '%s'", token.Lexeme), message)
+	report(Diagnostic{
+		Severity: SEVERITY_ERROR,
+		Code:     CODE_RESOLVE_ERROR,
+		Location: Location{Line: token.Line, Column: token.Column},
+		Message:  message,
+	}, fmt.Sprintf(" at '%s'", token.Lexeme))
 }
\ No newline at end of file
@@ -2,7 +2,10 @@ package main
 
 import (
 	"fmt"
+	"math/big"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 // Scanner performs lexical analysis to convert source code into tokens
@@ -12,36 +15,57 @@ type Scanner struct {
 	start   int
 	current int
 	line    int
+
+	// lineStart is the offset of the first character on the current line and
+	// startColumn the 1-based column of the token being scanned
+	lineStart   int
+	startColumn int
+
+	// interpolations holds, for every open "${", how many '{' are nested inside it
+	interpolations []int
 }
 
 var keywords = map[string]TokenType{
 	"and":    AND,
+	"async":  ASYNC,
+	"await":  AWAIT,
+	"const":  CONST,
 	"class":  CLASS,
+	"div":    DIV,
 	"else":   ELSE,
 	"false":  FALSE,
 	"for":    FOR,
 	"fun":    FUN,
 	"if":     IF,
+	"match":  MATCH,
 	"nil":    NIL,
 	"or":     OR,
 	"print":  PRINT,
 	"return": RETURN,
+	"select": SELECT,
+	"spawn":  SPAWN,
 	"super":  SUPER,
 	"this":   THIS,
 	"true":   TRUE,
 	"var":    VAR,
 	"while":  WHILE,
+	"yield":  YIELD,
 }
 
 // ScanTokens scans all tokens in the source
 func (s *Scanner) ScanTokens() []Token {
 	for !s.isAtEnd() {
 		s.start = s.current
+		s.startColumn = s.column()
 		s.scanToken()
 	}
 
+	if len(s.interpolations) > 0 {
+		error(s.line, s.column(), "Unterminated string interpolation.")
+	}
+
 	// Add EOF token
-	s.tokens = append(s.tokens, *NewToken(s.line, EOF, nil, ""))
+	s.tokens = append(s.tokens, *NewToken(s.line, s.column(), EOF, nil, ""))
 	return s.tokens
 }
 
@@ -58,25 +82,106 @@ func (s *Scanner) scanToken() {
 		s.addToken(LEFT_PAREN)
 	case ')':
 		s.addToken(RIGHT_PAREN)
+	case '[':
+		s.addToken(LEFT_BRACKET)
+	case ']':
+		s.addToken(RIGHT_BRACKET)
 	case '{':
+		if depth := len(s.interpolations); depth > 0 {
+			s.interpolations[depth-1]++
+		}
 		s.addToken(LEFT_BRACE)
 	case '}':
+		if depth := len(s.interpolations); depth > 0 {
+			if s.interpolations[depth-1] == 0 {
+				// This brace closes "${", the rest of the string literal follows
+				s.interpolations = s.interpolations[:depth-1]
+				s.addToken(INTERPOLATION_END)
+				s.start = s.current
+				s.startColumn = s.column()
+				s.string()
+				break
+			}
+			s.interpolations[depth-1]--
+		}
 		s.addToken(RIGHT_BRACE)
 	case '.':
-		s.addToken(DOT)
+		if s.peek() == '.' && s.peekNext() == '.' {
+			s.current += 2
+			s.addToken(ELLIPSIS)
+		} else if s.match('.') {
+			s.addToken(DOT_DOT)
+		} else {
+			s.addToken(DOT)
+		}
 	case ',':
 		s.addToken(COMMA)
 	case ';':
 		s.addToken(SEMICOLON)
 	case '+':
-		s.addToken(PLUS)
+		if s.match('=') {
+			s.addToken(PLUS_EQUAL)
+		} else if s.match('+') {
+			s.addToken(PLUS_PLUS)
+		} else {
+			s.addToken(PLUS)
+		}
 	case '-':
-		s.addToken(MINUS)
+		if s.match('=') {
+			s.addToken(MINUS_EQUAL)
+		} else if s.match('-') {
+			s.addToken(MINUS_MINUS)
+		} else {
+			s.addToken(MINUS)
+		}
 	case '*':
-		s.addToken(STAR)
+		if s.match('=') {
+			s.addToken(STAR_EQUAL)
+		} else if s.match('*') {
+			s.addToken(STAR_STAR)
+		} else {
+			s.addToken(STAR)
+		}
+	case ':':
+		s.addToken(COLON)
+	case '?':
+		if s.match('?') {
+			s.addToken(QUESTION_QUESTION)
+		} else if s.peek() == '.' && !s.isDigit(s.peekNext()) {
+			// Like in JavaScript, `a?.5:1` is a conditional on a number, not `?.`
+			s.advance()
+			s.addToken(QUESTION_DOT)
+		} else {
+			s.addToken(QUESTION)
+		}
+	case '#':
+		if s.isAlpha(s.peek()) {
+			for s.isAlphaNumneric(s.peek()) {
+				s.advance()
+			}
+			s.addToken(PRIVATE_IDENTIFIER)
+		} else {
+			s.error("Unexpected character: #")
+		}
+	case '&':
+		s.addToken(AMPERSAND)
+	case '|':
+		s.addToken(PIPE)
+	case '^':
+		s.addToken(CARET)
+	case '~':
+		s.addToken(TILDE)
+	case '%':
+		if s.match('=') {
+			s.addToken(PERCENT_EQUAL)
+		} else {
+			s.addToken(PERCENT)
+		}
 	case '=':
 		if s.match('=') {
 			s.addToken(EQUAL_EQUAL)
+		} else if s.match('>') {
+			s.addToken(ARROW)
 		} else {
 			s.addToken(EQUAL)
 		}
@@ -89,12 +194,16 @@ func (s *Scanner) scanToken() {
 	case '<':
 		if s.match('=') {
 			s.addToken(LESS_EQUAL)
+		} else if s.match('<') {
+			s.addToken(LESS_LESS)
 		} else {
 			s.addToken(LESS)
 		}
 	case '>':
 		if s.match('=') {
 			s.addToken(GREATER_EQUAL)
+		} else if s.match('>') {
+			s.addToken(GREATER_GREATER)
 		} else {
 			s.addToken(GREATER)
 		}
@@ -103,6 +212,10 @@ func (s *Scanner) scanToken() {
 			for !s.isAtEnd() && s.peek() != '\n' {
 				s.advance()
 			}
+		} else if s.match('*') {
+			s.blockComment()
+		} else if s.match('=') {
+			s.addToken(SLASH_EQUAL)
 		} else {
 			s.addToken(SLASH)
 		}
@@ -111,10 +224,15 @@ func (s *Scanner) scanToken() {
 	case '\t':
 		break
 	case '\n':
-		s.line++
+		s.newline()
 		break
 	case '"':
-		s.string()
+		if s.peek() == '"' && s.peekNext() == '"' {
+			s.current += 2
+			s.rawString()
+		} else {
+			s.string()
+		}
 		break
 	default:
 		// Use the error function instead of just printing
@@ -123,11 +241,27 @@ func (s *Scanner) scanToken() {
 		} else if s.isAlpha(c) {
 			s.identifier()
 		} else {
-			error(s.line, fmt.Sprintf("Unexpected character: %c", c))
+			s.error(fmt.Sprintf("Unexpected character: %c", c))
 		}
 	}
 }
 
+// column returns the 1-based column of the next character to be consumed
+func (s *Scanner) column() int {
+	return s.current - s.lineStart + 1
+}
+
+// newline records that a line break has just been consumed
+func (s *Scanner) newline() {
+	s.line++
+	s.lineStart = s.current
+}
+
+// error reports a scan error at the start of the current token
+func (s *Scanner) error(message string, related ...RelatedLocation) {
+	error(s.line, s.startColumn, message, related...)
+}
+
 // advance consumes the next character in the source
 func (s *Scanner) advance() byte {
 	if s.isAtEnd() {
@@ -157,32 +291,174 @@ func (s *Scanner) peek() byte {
 	}
 }
 
+func (s *Scanner) previous() byte {
+	return s.source[s.current-1]
+}
+
 func (s *Scanner) peekNext() byte {
-	if s.current+1 >= len(s.source) {
+	return s.peekAt(1)
+}
+
+// peekAt looks offset characters past the next one without consuming anything
+func (s *Scanner) peekAt(offset int) byte {
+	if s.current+offset >= len(s.source) {
 		return 0
 	}
-	return s.source[s.current+1]
+	return s.source[s.current+offset]
 }
 
+// string scans a string literal, or the part of one that follows an interpolated
+// expression. A part ending in "${" becomes an INTERPOLATION token and the embedded
+// expression is scanned as ordinary tokens until its closing '}', which becomes an
+// INTERPOLATION_END token.
 func (s *Scanner) string() {
+	startLine := s.line
+	var value strings.Builder
+
 	for !s.isAtEnd() && s.peek() != '"' {
-		if s.peek() == '\n' {
-			s.line++
+		c := s.advance()
+		switch {
+		case c == '$' && s.peek() == '{':
+			s.advance()
+			s.interpolations = append(s.interpolations, 0)
+			s.addTokenLiteral(INTERPOLATION, value.String())
+			return
+		case c == '\\':
+			s.escape(&value)
+		case c == '\n':
+			s.newline()
+			value.WriteByte(c)
+		default:
+			value.WriteByte(c)
 		}
-		s.advance()
 	}
 
 	if s.isAtEnd() {
-		error(s.line, "Unterminated string.")
+		error(s.line, s.column(), "Unterminated string.", RelatedLocation{
+			Location: Location{Line: startLine, Column: s.startColumn},
+			Message:  "String starts here.",
+		})
 		return
 	}
 
 	s.advance()
 
-	value := s.source[s.start+1 : s.current-1]
+	s.addTokenLiteral(STRING, value.String())
+}
+
+// escape decodes the escape sequence following a backslash into value
+func (s *Scanner) escape(value *strings.Builder) {
+	column := s.column() - 1
+	c := s.advance()
 
-	s.addTokenLiteral(STRING, value)
+	switch c {
+	case 'n':
+		value.WriteByte('\n')
+	case 't':
+		value.WriteByte('\t')
+	case 'r':
+		value.WriteByte('\r')
+	case '0':
+		value.WriteByte(0)
+	case '"', '\\', '$':
+		value.WriteByte(c)
+	case 'x':
+		digits := s.hexDigits(2)
+		if len(digits) != 2 {
+			error(s.line, column, "Invalid escape sequence '\\x': expected two hex digits.")
+			return
+		}
+		// Like in JavaScript, \xNN is the code point U+00NN, the Latin-1 character NN,
+		// rather than a raw byte: "\xff" is "ÿ", two bytes in UTF-8
+		code, _ := strconv.ParseUint(digits, 16, 32)
+		value.WriteRune(rune(code))
+	case 'u':
+		if !s.match('{') {
+			error(s.line, column, "Invalid escape sequence '\\u': expected '{'.")
+			return
+		}
+		digits := s.hexDigits(6)
+		if !s.match('}') || len(digits) == 0 {
+			error(s.line, column, "Invalid escape sequence '\\u': expected 1 to 6 hex digits and '}'.")
+			return
+		}
+		code, _ := strconv.ParseUint(digits, 16, 32)
+		if !utf8.ValidRune(rune(code)) {
+			error(s.line, column, fmt.Sprintf("Invalid unicode code point U+%s.", strings.ToUpper(digits)))
+			return
+		}
+		value.WriteRune(rune(code))
+	default:
+		if c == 0 {
+			return // the unterminated string is reported by the caller
+		}
+		if c == '\n' {
+			s.newline()
+		}
+		error(s.line, column, fmt.Sprintf("Invalid escape sequence '\\%c'.", c))
+	}
+}
+
+// hexDigits consumes up to max hexadecimal digits
+func (s *Scanner) hexDigits(max int) string {
+	start := s.current
+	for s.current-start < max && s.isHexDigit(s.peek()) {
+		s.advance()
+	}
+	return s.source[start:s.current]
+}
+
+// blockComment skips a /* ... */ comment, which may contain nested block comments
+func (s *Scanner) blockComment() {
+	startLine := s.line
+	nesting := 1
+
+	for !s.isAtEnd() {
+		c := s.advance()
+		switch {
+		case c == '\n':
+			s.newline()
+		case c == '/' && s.peek() == '*':
+			s.advance()
+			nesting++
+		case c == '*' && s.peek() == '/':
+			s.advance()
+			nesting--
+			if nesting == 0 {
+				return
+			}
+		}
+	}
+
+	error(s.line, s.column(), fmt.Sprintf("Unterminated block comment starting on line %d.", startLine), RelatedLocation{
+		Location: Location{Line: startLine, Column: s.startColumn},
+		Message:  "Comment starts here.",
+	})
+}
+
+// rawString scans a triple-quoted string, whose content is kept verbatim and may span lines
+func (s *Scanner) rawString() {
+	startLine := s.line
+	contentStart := s.current
+
+	for !s.isAtEnd() && !(s.peek() == '"' && s.peekNext() == '"' && s.peekAt(2) == '"') {
+		if s.advance() == '\n' {
+			s.newline()
+		}
+	}
+
+	if s.isAtEnd() {
+		error(s.line, s.column(), "Unterminated raw string.", RelatedLocation{
+			Location: Location{Line: startLine, Column: s.startColumn},
+			Message:  "String starts here.",
+		})
+		return
+	}
+
+	value := s.source[contentStart:s.current]
+	s.current += 3
 
+	s.addTokenLiteral(STRING, value)
 }
 
 func (s *Scanner) number() {
@@ -199,9 +475,21 @@ func (s *Scanner) number() {
 
 	numStr := s.source[s.start:s.current]
 
+	if !strings.Contains(numStr, ".") {
+		// Integer literals are exact, falling back to a big integer beyond int64
+		if value, err := strconv.ParseInt(numStr, 10, 64); err == nil {
+			s.addTokenLiteral(NUMBER, value)
+		} else if value, ok := new(big.Int).SetString(numStr, 10); ok {
+			s.addTokenLiteral(NUMBER, value)
+		} else {
+			s.error("Invalid number.")
+		}
+		return
+	}
+
 	value, err := strconv.ParseFloat(numStr, 64)
 	if err != nil {
-		error(s.line, "Invalid number.")
+		s.error("Invalid number.")
 		return
 	}
 
@@ -226,6 +514,10 @@ func (s *Scanner) isDigit(expected byte) bool {
 	return expected >= '0' && expected <= '9'
 }
 
+func (s *Scanner) isHexDigit(expected byte) bool {
+	return s.isDigit(expected) || (expected >= 'a' && expected <= 'f') || (expected >= 'A' && expected <= 'F')
+}
+
 func (s *Scanner) isAlpha(expected byte) bool {
 	return (expected >= 'a' && expected <= 'z') || (expected >= 'A' && expected <= 'Z' || expected == '_')
 }
@@ -242,5 +534,5 @@ func (s *Scanner) addToken(TokenType TokenType) {
 // addTokenLiteral adds a token with a Literal value
 func (s *Scanner) addTokenLiteral(TokenType TokenType, Literal Object) {
 	text := s.source[s.start:s.current]
-	s.tokens = append(s.tokens, *NewToken(s.line, TokenType, Literal, text))
+	s.tokens = append(s.tokens, *NewToken(s.line, s.startColumn, TokenType, Literal, text))
 }
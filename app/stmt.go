@@ -14,6 +14,13 @@ type StmtVisitor interface {
 	visitFunctionStmt(stmt *FunctionStatement) interface{}
 	visitReturnStmt(stmt *ReturnStatement) interface{}
 	visitClassStmt(stmt *ClassStatement) interface{} 
+	visitTraitStmt(stmt *TraitStatement) interface{}
+	visitForInStmt(stmt *ForInStatement) interface{}
+	visitYieldStmt(stmt *YieldStatement) interface{}
+	visitSelectStmt(stmt *SelectStatement) interface{}
+	visitEnumStmt(stmt *EnumStatement) interface{}
+	visitMatchStmt(stmt *MatchStatement) interface{}
+	visitDestructureStmt(stmt *DestructureStatement) interface{}
 }
 
 type ExpressionStatement struct {
@@ -27,6 +34,25 @@ type PrintStatement struct {
 type VarStatement struct {
 	Name        Token
 	Initializer Expr
+	// IsConst marks a `const` declaration, whose binding can't be reassigned
+	IsConst bool
+	// Type is the optional `: Type` annotation
+	Type *TypeAnnotation
+}
+
+// DestructureStatement is `var [a, b] = value;` or `var {"key": a} = value;`, declaring
+// the names bound by matching value against the pattern
+type DestructureStatement struct {
+	Keyword     Token
+	Pattern     Pattern
+	Initializer Expr
+}
+
+// TypeAnnotation is a `Name` or `Name?` type written after a ':'. Annotations are only
+// read by the type checker of the `check` command, `run` ignores them.
+type TypeAnnotation struct {
+	Name     Token
+	Nullable bool
 }
 
 type Block struct {
@@ -44,10 +70,31 @@ type WhileStatement struct {
 	Body      Stmt
 }
 
+// ForInStatement is `for (name in iterable) body`. Every iteration binds name afresh.
+type ForInStatement struct {
+	Name     Token
+	In       Token
+	Iterable Expr
+	Body     Stmt
+}
+
 type FunctionStatement struct {
 	Body   []Stmt
 	Params []Token
-	Name   Token
+	// Defaults holds the default value of each parameter, nil for required ones
+	Defaults []Expr
+	// Rest is the `...name` parameter collecting extra arguments into a list
+	Rest *Token
+	// ParamTypes holds the annotation of each parameter, nil for unannotated ones
+	ParamTypes []*TypeAnnotation
+	ReturnType *TypeAnnotation
+	Name Token
+	// IsGetter marks a method declared without a parameter list, run when the property is read
+	IsGetter bool
+	// IsGenerator is set by the resolver when the body contains `yield`
+	IsGenerator bool
+	// IsAsync marks an `async` function, whose calls return a promise
+	IsAsync bool
 }
 
 type ReturnStatement struct {
@@ -55,10 +102,66 @@ type ReturnStatement struct {
 	Keyword Token
 }
 
+// YieldStatement is `yield [value];`, which hands value to whoever drives the generator
+type YieldStatement struct {
+	Keyword Token
+	Value   Expr
+}
+
+// SelectStatement waits until one of its cases can proceed and runs that case's body
+type SelectStatement struct {
+	Keyword Token
+	Cases   []*SelectCase
+}
+
+// SelectCase is `receive [name] from channel { ... }`, `send value to channel { ... }`
+// or `default { ... }`, told apart by the lexeme of Kind
+type SelectCase struct {
+	Kind    Token
+	Name    *Token
+	Channel Expr
+	Value   Expr
+	Body    []Stmt
+}
+
 type ClassStatement struct {
 	Name Token 
 	Methods []*FunctionStatement
+	// Fields are the typed instance fields `name: Type [= initializer];`
+	Fields []*VarStatement
 	Superclass *VariableExpr
+	// Traits are the traits listed after `with`, mixed in in order
+	Traits []*VariableExpr
+	// StaticMethods and StaticFields are the `static` members, which live on the class itself
+	StaticMethods []*FunctionStatement
+	StaticFields  []*VarStatement
+	// Setters are the `set name(value)` methods, run when the property is assigned
+	Setters []*FunctionStatement
+	// PrivateMethods and PrivateFields are the `#name` members, only reachable through
+	// `this` inside this class body
+	PrivateMethods []*FunctionStatement
+	PrivateFields  []*VarStatement
+}
+
+// TraitStatement is `trait Name { ... }`, a reusable set of methods and setters
+type TraitStatement struct {
+	Name    Token
+	Methods []*FunctionStatement
+	Setters []*FunctionStatement
+}
+
+// EnumStatement is `enum Name { Case, ... }`
+type EnumStatement struct {
+	Name  Token
+	Cases []Token
+}
+
+// MatchStatement is `match (subject) { pattern [if guard] => statement ... }`. It runs
+// the body of the first arm whose pattern matches, or nothing.
+type MatchStatement struct {
+	Keyword Token
+	Subject Expr
+	Arms    []*MatchArm
 }
 
 func (s *ExpressionStatement) Accept(visitor StmtVisitor) interface{} {
@@ -97,3 +200,31 @@ func (s *ReturnStatement) Accept(visitor StmtVisitor) interface{} {
 func (s *ClassStatement) Accept(visitor StmtVisitor) interface{} {
 	return visitor.visitClassStmt(s)
 }
+
+func (s *TraitStatement) Accept(visitor StmtVisitor) interface{} {
+	return visitor.visitTraitStmt(s)
+}
+
+func (s *ForInStatement) Accept(visitor StmtVisitor) interface{} {
+	return visitor.visitForInStmt(s)
+}
+
+func (s *YieldStatement) Accept(visitor StmtVisitor) interface{} {
+	return visitor.visitYieldStmt(s)
+}
+
+func (s *SelectStatement) Accept(visitor StmtVisitor) interface{} {
+	return visitor.visitSelectStmt(s)
+}
+
+func (s *EnumStatement) Accept(visitor StmtVisitor) interface{} {
+	return visitor.visitEnumStmt(s)
+}
+
+func (s *MatchStatement) Accept(visitor StmtVisitor) interface{} {
+	return visitor.visitMatchStmt(s)
+}
+
+func (s *DestructureStatement) Accept(visitor StmtVisitor) interface{} {
+	return visitor.visitDestructureStmt(s)
+}
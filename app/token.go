@@ -11,12 +11,24 @@ const (
 	RIGHT_BRACE TokenType = "RIGHT_BRACE"
 	LEFT_PAREN  TokenType = "LEFT_PAREN"
 	RIGHT_PAREN TokenType = "RIGHT_PAREN"
+	LEFT_BRACKET  TokenType = "LEFT_BRACKET"
+	RIGHT_BRACKET TokenType = "RIGHT_BRACKET"
 	DOT         TokenType = "DOT"
+	DOT_DOT     TokenType = "DOT_DOT"
+	ELLIPSIS    TokenType = "ELLIPSIS"
 	SEMICOLON   TokenType = "SEMICOLON"
 	MINUS       TokenType = "MINUS"
 	PLUS        TokenType = "PLUS"
 	COMMA       TokenType = "COMMA"
 	STAR        TokenType = "STAR"
+	PERCENT     TokenType = "PERCENT"
+	AMPERSAND   TokenType = "AMPERSAND"
+	PIPE        TokenType = "PIPE"
+	CARET       TokenType = "CARET"
+	TILDE       TokenType = "TILDE"
+	COLON       TokenType = "COLON"
+	// ARROW separates a match arm's pattern from its body
+	ARROW       TokenType = "ARROW"
 
 	// One or two character tokens
 	EQUAL         TokenType = "EQUAL"
@@ -28,20 +40,44 @@ const (
 	GREATER       TokenType = "GREATER"
 	GREATER_EQUAL TokenType = "GREATER_EQUAL"
 	SLASH         TokenType = "SLASH"
+	STAR_STAR     TokenType = "STAR_STAR"
+	LESS_LESS     TokenType = "LESS_LESS"
+	GREATER_GREATER TokenType = "GREATER_GREATER"
+	QUESTION          TokenType = "QUESTION"
+	QUESTION_QUESTION TokenType = "QUESTION_QUESTION"
+	QUESTION_DOT      TokenType = "QUESTION_DOT"
+
+	// Compound assignment, increment and decrement
+	PLUS_EQUAL    TokenType = "PLUS_EQUAL"
+	MINUS_EQUAL   TokenType = "MINUS_EQUAL"
+	STAR_EQUAL    TokenType = "STAR_EQUAL"
+	SLASH_EQUAL   TokenType = "SLASH_EQUAL"
+	PERCENT_EQUAL TokenType = "PERCENT_EQUAL"
+	PLUS_PLUS     TokenType = "PLUS_PLUS"
+	MINUS_MINUS   TokenType = "MINUS_MINUS"
 
 	// Literals
 	STRING     TokenType = "STRING"
+	// INTERPOLATION is the part of a string literal before an embedded "${...}" expression
+	INTERPOLATION TokenType = "INTERPOLATION"
+	// INTERPOLATION_END is the '}' closing an embedded "${...}" expression
+	INTERPOLATION_END TokenType = "INTERPOLATION_END"
 	NUMBER     TokenType = "NUMBER"
 	IDENTIFIER TokenType = "IDENTIFIER"
+	// PRIVATE_IDENTIFIER is a `#name` naming a private class member
+	PRIVATE_IDENTIFIER TokenType = "PRIVATE_IDENTIFIER"
 
 	// Keywords
 	AND    TokenType = "AND"
 	CLASS  TokenType = "CLASS"
+	CONST  TokenType = "CONST"
+	DIV    TokenType = "DIV"
 	ELSE   TokenType = "ELSE"
 	FALSE  TokenType = "FALSE"
 	FOR    TokenType = "FOR"
 	FUN    TokenType = "FUN"
 	IF     TokenType = "IF"
+	MATCH  TokenType = "MATCH"
 	NIL    TokenType = "NIL"
 	OR     TokenType = "OR"
 	PRINT  TokenType = "PRINT"
@@ -51,6 +87,11 @@ const (
 	TRUE   TokenType = "TRUE"
 	VAR    TokenType = "VAR"
 	WHILE  TokenType = "WHILE"
+	YIELD  TokenType = "YIELD"
+	SPAWN  TokenType = "SPAWN"
+	AWAIT  TokenType = "AWAIT"
+	ASYNC  TokenType = "ASYNC"
+	SELECT TokenType = "SELECT"
 
 	// End of file
 	EOF TokenType = "EOF"
@@ -65,12 +106,14 @@ type Token struct {
 	Lexeme    string
 	Literal   Object
 	Line      int
+	Column    int
 }
 
 // NewToken creates a new token
-func NewToken(Line int, TokenType TokenType, Literal Object, Lexeme string) *Token {
+func NewToken(Line int, Column int, TokenType TokenType, Literal Object, Lexeme string) *Token {
 	t := new(Token)
 	t.Line = Line
+	t.Column = Column
 	t.TokenType = TokenType
 	t.Literal = Literal
 	t.Lexeme = Lexeme
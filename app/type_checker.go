@@ -0,0 +1,1020 @@
+package main
+
+import "fmt"
+
+// TypeChecker checks a resolved program against its type annotations for the `check`
+// command. Unannotated globals, parameters and results are `any`, which fits everything,
+// while unannotated locals take the type of their initializer until assigned another.
+type TypeChecker struct {
+	scopes []map[string]*typeBinding
+	// classes holds the type of each class declaration, created when its block is entered
+	classes    map[*ClassStatement]*ClassShape
+	signatures map[*FunctionStatement]*Signature
+	// currentClass is the class whose body is checked, currentThis the type of `this` there
+	currentClass *ClassShape
+	currentThis  *Type
+	// currentReturn is what return statements in the enclosing function must produce
+	currentReturn *Type
+}
+
+// typeBinding is the declared type of a variable, along with the narrower type it has
+// where it was compared against nil
+type typeBinding struct {
+	declared *Type
+	current  *Type
+	// inferred is set when declared was taken from an initializer rather than written
+	// down, so assigning a value of another type widens it instead of being an error
+	inferred bool
+	// narrows is the binding this one stands in for where it was narrowed
+	narrows *typeBinding
+}
+
+func NewTypeChecker() *TypeChecker {
+	c := &TypeChecker{
+		scopes:        make([]map[string]*typeBinding, 0),
+		classes:       make(map[*ClassStatement]*ClassShape),
+		signatures:    make(map[*FunctionStatement]*Signature),
+		currentThis:   anyType,
+		currentReturn: anyType,
+	}
+	c.beginScope()
+	return c
+}
+
+func (c *TypeChecker) check(statements []Stmt) {
+	c.checkStatements(statements)
+}
+
+func (c *TypeChecker) typeOf(expr Expr) *Type {
+	return expr.Accept(c).(*Type)
+}
+
+func (c *TypeChecker) beginScope() {
+	c.scopes = append(c.scopes, make(map[string]*typeBinding))
+}
+
+func (c *TypeChecker) endScope() {
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+func (c *TypeChecker) declare(name string, declared *Type) {
+	c.scopes[len(c.scopes)-1][name] = &typeBinding{declared: declared, current: declared}
+}
+
+// assign checks a value of type value assigned to the variable of binding, which is no
+// longer narrowed afterwards
+func (c *TypeChecker) assign(name Token, binding *typeBinding, value *Type) {
+	if binding.inferred && !value.assignableTo(binding.declared) {
+		widened := anyType
+		if value.Kind == TYPE_NIL || value.nonNil().assignableTo(binding.declared) {
+			widened = binding.declared.orNil()
+		}
+		for widening := binding; widening != nil; widening = widening.narrows {
+			widening.declared = widened
+			widening.current = widened
+		}
+	}
+	c.expect(name, value, binding.declared, "")
+	binding.current = binding.declared
+}
+
+func (c *TypeChecker) lookup(name string) *typeBinding {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if binding, exists := c.scopes[i][name]; exists {
+			return binding
+		}
+	}
+	return nil
+}
+
+// hoist declares the classes and functions of a block up front, so annotations and
+// function bodies may refer to the ones declared further down
+func (c *TypeChecker) hoist(statements []Stmt) {
+	for _, statement := range statements {
+		if class, ok := statement.(*ClassStatement); ok {
+			classType := NewClassShape(class.Name.Lexeme)
+			c.classes[class] = classType
+			c.declare(class.Name.Lexeme, &Type{Kind: TYPE_CLASS, Class: classType})
+		}
+	}
+
+	for _, statement := range statements {
+		switch declaration := statement.(type) {
+		case *ClassStatement:
+			c.declareMembers(declaration)
+		case *FunctionStatement:
+			c.declare(declaration.Name.Lexeme, signatureType(c.signatureOf(declaration)))
+		}
+	}
+}
+
+// declareMembers fills in the class type of stmt from its member declarations
+func (c *TypeChecker) declareMembers(stmt *ClassStatement) {
+	class := c.classes[stmt]
+	if stmt.Superclass != nil {
+		if binding := c.lookup(stmt.Superclass.Name.Lexeme); binding != nil && binding.declared.Kind == TYPE_CLASS {
+			class.Superclass = binding.declared.Class
+		}
+	}
+	class.Open = len(stmt.Traits) > 0
+
+	for _, field := range stmt.Fields {
+		class.Fields[field.Name.Lexeme] = c.resolveType(field.Type)
+	}
+	for _, method := range stmt.Methods {
+		if method.IsGetter {
+			class.Getters[method.Name.Lexeme] = c.signatureOf(method).Return
+		} else {
+			class.Methods[method.Name.Lexeme] = c.signatureOf(method)
+		}
+	}
+	for _, setter := range stmt.Setters {
+		if signature := c.signatureOf(setter); len(signature.Params) == 1 {
+			class.Setters[setter.Name.Lexeme] = signature.Params[0]
+		}
+	}
+	for _, method := range stmt.StaticMethods {
+		class.Statics[method.Name.Lexeme] = signatureType(c.signatureOf(method))
+	}
+	for _, field := range stmt.StaticFields {
+		class.Statics[field.Name.Lexeme] = c.resolveType(field.Type)
+	}
+}
+
+// resolveType turns an annotation into a type. A missing annotation means any type.
+func (c *TypeChecker) resolveType(annotation *TypeAnnotation) *Type {
+	if annotation == nil {
+		return anyType
+	}
+
+	resolved, builtin := builtinTypes[annotation.Name.Lexeme]
+	if !builtin {
+		binding := c.lookup(annotation.Name.Lexeme)
+		if binding == nil || binding.declared.Kind != TYPE_CLASS {
+			c.error(annotation.Name, fmt.Sprintf("Unknown type '%s'.", annotation.Name.Lexeme))
+			return anyType
+		}
+		resolved = instanceType(binding.declared.Class)
+	}
+
+	if annotation.Nullable {
+		return resolved.orNil()
+	}
+	return resolved
+}
+
+// signatureOf builds the signature of function once, so its annotations are only
+// resolved and reported once
+func (c *TypeChecker) signatureOf(function *FunctionStatement) *Signature {
+	if signature, ok := c.signatures[function]; ok {
+		return signature
+	}
+
+	signature := &Signature{
+		Name:     function.Name.Lexeme,
+		Params:   make([]*Type, 0, len(function.Params)),
+		Names:    make([]string, 0, len(function.Params)),
+		Variadic: function.Rest != nil,
+		Return:   c.resolveType(function.ReturnType),
+		Wrapped:  function.IsAsync || function.IsGenerator,
+	}
+	for index, param := range function.Params {
+		signature.Params = append(signature.Params, c.resolveType(function.ParamTypes[index]))
+		signature.Names = append(signature.Names, param.Lexeme)
+		if function.Defaults[index] == nil {
+			signature.Required = index + 1
+		}
+	}
+
+	c.signatures[function] = signature
+	return signature
+}
+
+// expect reports a value of type actual used where expected is required. context
+// describes the value when token alone doesn't.
+func (c *TypeChecker) expect(token Token, actual *Type, expected *Type, context string) {
+	if !actual.assignableTo(expected) {
+		c.error(token, fmt.Sprintf("Expected '%s'%s but got '%s'.", expected, context, actual))
+	}
+}
+
+func (c *TypeChecker) expectNumbers(operator Token, left *Type, right *Type) {
+	if !left.assignableTo(numberType) || !right.assignableTo(numberType) {
+		c.error(operator, fmt.Sprintf("Operands of '%s' must be numbers, got '%s' and '%s'.", operator.Lexeme, left, right))
+	}
+}
+
+// nilTest recognizes a condition that tells whether a variable is nil: `name != nil`,
+// `name == nil` or just `name`. present is whether the condition holds when it isn't nil.
+func nilTest(condition Expr) (variable *VariableExpr, present bool) {
+	switch test := condition.(type) {
+	case *VariableExpr:
+		return test, true
+	case *GroupingExpr:
+		return nilTest(test.Expression)
+	case *BinaryExpr:
+		if test.Operator.TokenType != BANG_EQUAL && test.Operator.TokenType != EQUAL_EQUAL {
+			return nil, false
+		}
+		operand := test.Left
+		if isNilLiteral(test.Left) {
+			operand = test.Right
+		} else if !isNilLiteral(test.Right) {
+			return nil, false
+		}
+		if variable, ok := operand.(*VariableExpr); ok {
+			return variable, test.Operator.TokenType == BANG_EQUAL
+		}
+	}
+	return nil, false
+}
+
+func isNilLiteral(expr Expr) bool {
+	literal, ok := expr.(*LiteralExpr)
+	return ok && literal.Value == nil
+}
+
+// narrowed runs check where variable is known not to be nil, when narrow is set
+func (c *TypeChecker) narrowed(variable *VariableExpr, narrow bool, check func()) {
+	if !narrow || variable == nil {
+		check()
+		return
+	}
+	binding := c.lookup(variable.Name.Lexeme)
+	if binding == nil || !binding.current.Nullable {
+		check()
+		return
+	}
+
+	c.beginScope()
+	c.scopes[len(c.scopes)-1][variable.Name.Lexeme] = binding.narrowed()
+	check()
+	c.endScope()
+}
+
+// alwaysReturns reports whether running statements always ends in a return statement
+func alwaysReturns(statements []Stmt) bool {
+	for _, statement := range statements {
+		switch stmt := statement.(type) {
+		case *ReturnStatement:
+			return true
+		case *Block:
+			if alwaysReturns(stmt.Statements) {
+				return true
+			}
+		case *IfStatement:
+			if stmt.ElseBranch != nil && alwaysReturns([]Stmt{stmt.ThenBranch}) && alwaysReturns([]Stmt{stmt.ElseBranch}) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *TypeChecker) checkStatements(statements []Stmt) {
+	c.hoist(statements)
+	for _, statement := range statements {
+		statement.Accept(c)
+		if guard, ok := statement.(*IfStatement); ok {
+			c.narrowAfterGuard(guard)
+		}
+	}
+}
+
+// narrowAfterGuard narrows a variable for the rest of the enclosing statements when the
+// branch of guard taken while it is nil always returns, as in `if (p == nil) return 0;`
+func (c *TypeChecker) narrowAfterGuard(guard *IfStatement) {
+	variable, present := nilTest(guard.Condition)
+	if variable == nil {
+		return
+	}
+	nilBranch := guard.ThenBranch
+	if present {
+		nilBranch = guard.ElseBranch
+	}
+	if nilBranch == nil || !alwaysReturns([]Stmt{nilBranch}) {
+		return
+	}
+	binding := c.lookup(variable.Name.Lexeme)
+	if binding == nil || !binding.current.Nullable {
+		return
+	}
+
+	c.scopes[len(c.scopes)-1][variable.Name.Lexeme] = binding.narrowed()
+}
+
+// narrowed returns a binding standing in for b where its variable isn't nil
+func (b *typeBinding) narrowed() *typeBinding {
+	return &typeBinding{
+		declared: b.declared,
+		current:  b.current.nonNil(),
+		inferred: b.inferred,
+		narrows:  b,
+	}
+}
+
+func (c *TypeChecker) checkFunction(function *FunctionStatement, functionType FunctionType) {
+	signature := c.signatureOf(function)
+	enclosingReturn := c.currentReturn
+	c.currentReturn = signature.Return
+	if functionType == FUNCTION_INITIALIZER {
+		c.currentReturn = anyType
+	}
+
+	c.beginScope()
+	for index, param := range function.Params {
+		if function.Defaults[index] != nil {
+			c.expect(param, c.typeOf(function.Defaults[index]), signature.Params[index], " for the default value")
+		}
+		c.declare(param.Lexeme, signature.Params[index])
+	}
+	if function.Rest != nil {
+		c.declare(function.Rest.Lexeme, listType)
+	}
+	c.checkStatements(function.Body)
+	c.endScope()
+
+	if function.ReturnType != nil && !function.IsGenerator && !nilType.assignableTo(c.currentReturn) && !alwaysReturns(function.Body) {
+		c.error(function.Name, fmt.Sprintf("Function '%s' must return a value of type '%s'.", function.Name.Lexeme, c.currentReturn))
+	}
+	c.currentReturn = enclosingReturn
+}
+
+// checkArguments checks the arguments of a call against signature and returns its result
+func (c *TypeChecker) checkArguments(expr *CallExpression, signature *Signature, positional []*Type, named []*Type) *Type {
+	count := len(positional) + len(named)
+	max := len(signature.Params)
+	if signature.Variadic {
+		max = -1
+	}
+	if count < signature.Required || (max != -1 && count > max) {
+		c.error(expr.Parenthesis, arityMessage(signature.Required, max, count))
+	}
+
+	for index, argument := range positional {
+		if index < len(signature.Params) {
+			c.expect(expr.Parenthesis, argument, signature.Params[index], fmt.Sprintf(" for argument '%s'", signature.Names[index]))
+		}
+	}
+	for index, name := range expr.Names {
+		for paramIndex, param := range signature.Names {
+			if param == name.Lexeme {
+				c.expect(name, named[index], signature.Params[paramIndex], fmt.Sprintf(" for argument '%s'", param))
+			}
+		}
+	}
+
+	if signature.Wrapped {
+		return anyType
+	}
+	return signature.Return
+}
+
+// binary is the type of applying operator to operands of type left and right
+func (c *TypeChecker) binary(operator Token, left *Type, right *Type) *Type {
+	if name, overloadable := operatorMethods[operator.TokenType]; overloadable && left.Kind == TYPE_INSTANCE && !left.Nullable {
+		if method := left.Class.findMethod(name); method != nil {
+			return c.operatorResult(operator, method, right)
+		}
+		if left.Class.isOpen() {
+			return anyType
+		}
+	}
+
+	switch operator.TokenType {
+	case EQUAL_EQUAL, BANG_EQUAL:
+		return boolType
+	case PLUS:
+		switch {
+		case left.Kind == TYPE_ANY || right.Kind == TYPE_ANY:
+			return anyType
+		case left.assignableTo(numberType) && right.assignableTo(numberType):
+			return numberType
+		case left.assignableTo(stringType) && right.assignableTo(stringType):
+			return stringType
+		}
+		c.error(operator, fmt.Sprintf("Operands of '+' must be two numbers or two strings, got '%s' and '%s'.", left, right))
+		return anyType
+	case GREATER, GREATER_EQUAL, LESS, LESS_EQUAL:
+		c.expectNumbers(operator, left, right)
+		return boolType
+	case DOT_DOT:
+		c.expectNumbers(operator, left, right)
+		return anyType
+	}
+
+	c.expectNumbers(operator, left, right)
+	return numberType
+}
+
+func (c *TypeChecker) operatorResult(operator Token, method *Signature, operand *Type) *Type {
+	if len(method.Params) > 0 {
+		c.expect(operator, operand, method.Params[0], fmt.Sprintf(" for the operand of '%s'", operator.Lexeme))
+	}
+	return method.Return
+}
+
+// property is the type of reading the property name of a non-nil value of type object
+func (c *TypeChecker) property(object *Type, name Token) *Type {
+	switch object.Kind {
+	case TYPE_INSTANCE:
+		if property, ok := object.Class.property(name.Lexeme); ok {
+			return property
+		}
+		if object.Class.isClosed() {
+			c.error(name, fmt.Sprintf("Undefined property '%s' on '%s'.", name.Lexeme, object.Class.Name))
+		}
+	case TYPE_CLASS:
+		if static, ok := object.Class.findStatic(name.Lexeme); ok {
+			return static
+		}
+	case TYPE_NIL, TYPE_NUMBER, TYPE_STRING, TYPE_BOOL, TYPE_FUNCTION:
+		c.error(name, "Only instances have properties.")
+	}
+	return anyType
+}
+
+func (c *TypeChecker) error(token Token, message string) {
+	report(Diagnostic{
+		Severity: SEVERITY_ERROR,
+		Code:     CODE_TYPE_ERROR,
+		Location: Location{Line: token.Line, Column: token.Column},
+		Message:  message,
+	}, fmt.Sprintf(" at '%s'", token.Lexeme))
+}
+
+func (c *TypeChecker) visitExpressionStmt(stmt *ExpressionStatement) interface{} {
+	if stmt.Expression != nil {
+		c.typeOf(stmt.Expression)
+	}
+	return nil
+}
+
+func (c *TypeChecker) visitPrintStmt(stmt *PrintStatement) interface{} {
+	c.typeOf(stmt.Value)
+	return nil
+}
+
+func (c *TypeChecker) visitVarStmt(stmt *VarStatement) interface{} {
+	var declared *Type
+	if stmt.Type != nil {
+		declared = c.resolveType(stmt.Type)
+	}
+
+	inferred := false
+	if stmt.Initializer != nil {
+		value := c.typeOf(stmt.Initializer)
+		if declared != nil {
+			c.expect(stmt.Name, value, declared, "")
+		} else if len(c.scopes) > 1 && value.Kind != TYPE_NIL {
+			declared, inferred = value, true
+		}
+	} else if declared != nil && !nilType.assignableTo(declared) {
+		c.error(stmt.Name, fmt.Sprintf("Variable '%s' of type '%s' needs an initializer.", stmt.Name.Lexeme, declared))
+	}
+
+	if declared == nil {
+		declared = anyType
+	}
+	c.declare(stmt.Name.Lexeme, declared)
+	c.lookup(stmt.Name.Lexeme).inferred = inferred
+	return nil
+}
+
+func (c *TypeChecker) visitBlockStmt(stmt *Block) interface{} {
+	c.beginScope()
+	c.checkStatements(stmt.Statements)
+	c.endScope()
+	return nil
+}
+
+func (c *TypeChecker) visitIfStmt(stmt *IfStatement) interface{} {
+	c.typeOf(stmt.Condition)
+	variable, present := nilTest(stmt.Condition)
+	c.narrowed(variable, present, func() {
+		stmt.ThenBranch.Accept(c)
+	})
+	if stmt.ElseBranch != nil {
+		c.narrowed(variable, !present, func() {
+			stmt.ElseBranch.Accept(c)
+		})
+	}
+	return nil
+}
+
+func (c *TypeChecker) visitWhileStmt(stmt *WhileStatement) interface{} {
+	c.typeOf(stmt.Condition)
+	variable, present := nilTest(stmt.Condition)
+	c.narrowed(variable, present, func() {
+		stmt.Body.Accept(c)
+	})
+	return nil
+}
+
+func (c *TypeChecker) visitForInStmt(stmt *ForInStatement) interface{} {
+	element := anyType
+	if iterable := c.typeOf(stmt.Iterable); iterable.Kind == TYPE_STRING && !iterable.Nullable {
+		element = stringType
+	}
+
+	c.beginScope()
+	c.declare(stmt.Name.Lexeme, element)
+	c.lookup(stmt.Name.Lexeme).inferred = true
+	stmt.Body.Accept(c)
+	c.endScope()
+	return nil
+}
+
+func (c *TypeChecker) visitFunctionStmt(stmt *FunctionStatement) interface{} {
+	c.declare(stmt.Name.Lexeme, signatureType(c.signatureOf(stmt)))
+	c.checkFunction(stmt, FUNCTION_FUNCTION)
+	return nil
+}
+
+func (c *TypeChecker) visitReturnStmt(stmt *ReturnStatement) interface{} {
+	returned := nilType
+	if stmt.Value != nil {
+		returned = c.typeOf(stmt.Value)
+	}
+	c.expect(stmt.Keyword, returned, c.currentReturn, " for the return value")
+	return nil
+}
+
+func (c *TypeChecker) visitYieldStmt(stmt *YieldStatement) interface{} {
+	if stmt.Value != nil {
+		c.typeOf(stmt.Value)
+	}
+	return nil
+}
+
+func (c *TypeChecker) visitSelectStmt(stmt *SelectStatement) interface{} {
+	for _, selectCase := range stmt.Cases {
+		if selectCase.Channel != nil {
+			c.typeOf(selectCase.Channel)
+		}
+		if selectCase.Value != nil {
+			c.typeOf(selectCase.Value)
+		}
+
+		c.beginScope()
+		if selectCase.Name != nil {
+			c.declare(selectCase.Name.Lexeme, anyType)
+		}
+		c.checkStatements(selectCase.Body)
+		c.endScope()
+	}
+	return nil
+}
+
+func (c *TypeChecker) visitEnumStmt(stmt *EnumStatement) interface{} {
+	c.declare(stmt.Name.Lexeme, anyType)
+	return nil
+}
+
+func (c *TypeChecker) visitMatchStmt(stmt *MatchStatement) interface{} {
+	c.typeOf(stmt.Subject)
+	for _, arm := range stmt.Arms {
+		c.beginScope()
+		c.checkPattern(arm)
+		arm.Body.Accept(c)
+		c.endScope()
+	}
+	return nil
+}
+
+func (c *TypeChecker) visitDestructureStmt(stmt *DestructureStatement) interface{} {
+	c.typeOf(stmt.Initializer)
+	c.bindPattern(stmt.Pattern)
+	return nil
+}
+
+// checkPattern checks the pattern and guard of a match arm
+func (c *TypeChecker) checkPattern(arm *MatchArm) {
+	c.bindPattern(arm.Pattern)
+	if arm.Guard != nil {
+		c.typeOf(arm.Guard)
+	}
+}
+
+// bindPattern checks the expressions inside pattern and declares the names it binds,
+// which can hold any value
+func (c *TypeChecker) bindPattern(pattern Pattern) {
+	c.checkPatternValues(pattern)
+	for _, name := range patternBindings(pattern) {
+		c.declare(name.Lexeme, anyType)
+	}
+}
+
+func (c *TypeChecker) checkPatternValues(pattern Pattern) {
+	switch pattern := pattern.(type) {
+	case *ValuePattern:
+		c.typeOf(pattern.Value)
+	case *ListPattern:
+		for _, element := range pattern.Elements {
+			c.checkPatternValues(element)
+		}
+	case *MapPattern:
+		for _, value := range pattern.Values {
+			c.checkPatternValues(value)
+		}
+	case *ClassPattern:
+		c.typeOf(pattern.Class)
+		for _, positional := range pattern.Positional {
+			c.checkPatternValues(positional)
+		}
+		for _, named := range pattern.Named {
+			c.checkPatternValues(named)
+		}
+	}
+}
+
+func (c *TypeChecker) visitClassStmt(stmt *ClassStatement) interface{} {
+	class := c.classes[stmt]
+	enclosingClass, enclosingThis := c.currentClass, c.currentThis
+	c.currentClass = class
+
+	c.currentThis = instanceType(class)
+	for _, field := range stmt.Fields {
+		if field.Initializer != nil {
+			c.expect(field.Name, c.typeOf(field.Initializer), class.Fields[field.Name.Lexeme], "")
+		}
+	}
+	for _, field := range stmt.PrivateFields {
+		if field.Initializer != nil && field.Type != nil {
+			c.expect(field.Name, c.typeOf(field.Initializer), c.resolveType(field.Type), "")
+		} else if field.Initializer != nil {
+			c.typeOf(field.Initializer)
+		}
+	}
+
+	for _, method := range stmt.Methods {
+		declaration := FUNCTION_METHOD
+		if method.Name.Lexeme == "init" {
+			declaration = FUNCTION_INITIALIZER
+		}
+		c.checkFunction(method, declaration)
+		c.checkOverride(class, method)
+	}
+	for _, functions := range [][]*FunctionStatement{stmt.Setters, stmt.PrivateMethods} {
+		for _, function := range functions {
+			c.checkFunction(function, FUNCTION_METHOD)
+		}
+	}
+
+	c.currentThis = &Type{Kind: TYPE_CLASS, Class: class}
+	for _, field := range stmt.StaticFields {
+		if field.Initializer != nil {
+			c.expect(field.Name, c.typeOf(field.Initializer), class.Statics[field.Name.Lexeme], "")
+		}
+	}
+	for _, method := range stmt.StaticMethods {
+		c.checkFunction(method, FUNCTION_METHOD)
+	}
+
+	c.currentClass, c.currentThis = enclosingClass, enclosingThis
+	return nil
+}
+
+// checkOverride reports a method that can't stand in for the superclass method it overrides
+func (c *TypeChecker) checkOverride(class *ClassShape, method *FunctionStatement) {
+	if method.IsGetter || method.Name.Lexeme == "init" || class.Superclass == nil {
+		return
+	}
+	overridden := class.Superclass.findMethod(method.Name.Lexeme)
+	if overridden != nil && !c.signatureOf(method).assignableTo(overridden) {
+		c.error(method.Name, fmt.Sprintf("Method '%s' of '%s' is incompatible with the one it overrides.", method.Name.Lexeme, class.Name))
+	}
+}
+
+func (c *TypeChecker) visitTraitStmt(stmt *TraitStatement) interface{} {
+	enclosingClass, enclosingThis := c.currentClass, c.currentThis
+	c.currentClass, c.currentThis = nil, anyType
+
+	for _, functions := range [][]*FunctionStatement{stmt.Methods, stmt.Setters} {
+		for _, function := range functions {
+			c.checkFunction(function, FUNCTION_METHOD)
+		}
+	}
+
+	c.currentClass, c.currentThis = enclosingClass, enclosingThis
+	return nil
+}
+
+func (c *TypeChecker) visitLiteralExpr(expr *LiteralExpr) interface{} {
+	switch expr.Value.(type) {
+	case nil:
+		return nilType
+	case string:
+		return stringType
+	case bool:
+		return boolType
+	}
+	if isNumber(expr.Value) {
+		return numberType
+	}
+	return anyType
+}
+
+func (c *TypeChecker) visitGroupingExpr(expr *GroupingExpr) interface{} {
+	return c.typeOf(expr.Expression)
+}
+
+func (c *TypeChecker) visitVariableExpr(expr *VariableExpr) interface{} {
+	if binding := c.lookup(expr.Name.Lexeme); binding != nil {
+		return binding.current
+	}
+	return anyType
+}
+
+func (c *TypeChecker) visitAssignmentExpr(expr *AssignmentExpr) interface{} {
+	value := c.typeOf(expr.Value)
+	if binding := c.lookup(expr.Name.Lexeme); binding != nil {
+		c.assign(expr.Name, binding, value)
+	}
+	return value
+}
+
+func (c *TypeChecker) visitUnaryExpr(expr *UnaryExpr) interface{} {
+	operand := c.typeOf(expr.Right)
+	switch expr.Operator.TokenType {
+	case BANG:
+		return boolType
+	case MINUS:
+		if operand.Kind == TYPE_INSTANCE && !operand.Nullable {
+			if method := operand.Class.findMethod("__neg__"); method != nil {
+				return method.Return
+			}
+		}
+	}
+
+	if !operand.assignableTo(numberType) {
+		c.error(expr.Operator, fmt.Sprintf("Operand of '%s' must be a number, got '%s'.", expr.Operator.Lexeme, operand))
+	}
+	return numberType
+}
+
+func (c *TypeChecker) visitBinaryExpr(expr *BinaryExpr) interface{} {
+	return c.binary(expr.Operator, c.typeOf(expr.Left), c.typeOf(expr.Right))
+}
+
+func (c *TypeChecker) visitLogicalExpr(expr *LogicalExpr) interface{} {
+	left := c.typeOf(expr.Left)
+	var right *Type
+
+	variable, present := nilTest(expr.Left)
+	switch expr.Operator.TokenType {
+	case QUESTION_QUESTION:
+		return join(left.nonNil(), c.typeOf(expr.Right))
+	case AND:
+		c.narrowed(variable, present, func() {
+			right = c.typeOf(expr.Right)
+		})
+	default:
+		c.narrowed(variable, !present, func() {
+			right = c.typeOf(expr.Right)
+		})
+	}
+	return join(left, right)
+}
+
+func (c *TypeChecker) visitConditionalExpr(expr *ConditionalExpr) interface{} {
+	c.typeOf(expr.Condition)
+	var thenType, elseType *Type
+
+	variable, present := nilTest(expr.Condition)
+	c.narrowed(variable, present, func() {
+		thenType = c.typeOf(expr.ThenBranch)
+	})
+	c.narrowed(variable, !present, func() {
+		elseType = c.typeOf(expr.ElseBranch)
+	})
+	return join(thenType, elseType)
+}
+
+func (c *TypeChecker) visitCallExpr(expr *CallExpression) interface{} {
+	callee := c.typeOf(expr.Callee)
+	positional := make([]*Type, 0, len(expr.Arguments))
+	for _, argument := range expr.Arguments {
+		positional = append(positional, c.typeOf(argument))
+	}
+	named := make([]*Type, 0, len(expr.NamedArguments))
+	for _, argument := range expr.NamedArguments {
+		named = append(named, c.typeOf(argument))
+	}
+
+	if callee.Nullable {
+		c.error(expr.Parenthesis, fmt.Sprintf("Can't call a value of type '%s' that may be nil.", callee))
+		return anyType
+	}
+
+	switch callee.Kind {
+	case TYPE_ANY:
+		return anyType
+	case TYPE_FUNCTION:
+		if callee.Signature == nil {
+			return anyType
+		}
+		return c.checkArguments(expr, callee.Signature, positional, named)
+	case TYPE_CLASS:
+		if initializer := callee.Class.findMethod("init"); initializer != nil {
+			c.checkArguments(expr, initializer, positional, named)
+		} else if count := len(positional) + len(named); count > 0 && !callee.Class.isOpen() {
+			c.error(expr.Parenthesis, arityMessage(0, 0, count))
+		}
+		return instanceType(callee.Class)
+	}
+
+	c.error(expr.Parenthesis, "Can only call functions and classes.")
+	return anyType
+}
+
+func (c *TypeChecker) visitGetExpr(expr *GetExpression) interface{} {
+	return c.get(expr, c.typeOf(expr.Object))
+}
+
+// get is the type of reading the property of expr from a value of type object
+func (c *TypeChecker) get(expr *GetExpression, object *Type) *Type {
+	if expr.Name.TokenType == PRIVATE_IDENTIFIER {
+		return anyType
+	}
+
+	if expr.Optional {
+		if object.Kind == TYPE_NIL {
+			return anyType
+		}
+		object = object.nonNil()
+	} else if object.Nullable {
+		c.error(expr.Name, fmt.Sprintf("Can't read property '%s' of a value of type '%s' that may be nil.", expr.Name.Lexeme, object))
+		return anyType
+	}
+	return c.property(object, expr.Name)
+}
+
+func (c *TypeChecker) visitOptionalChainExpr(expr *OptionalChainExpr) interface{} {
+	return c.typeOf(expr.Expression).orNil()
+}
+
+func (c *TypeChecker) visitSetExpr(expr *SetExpression) interface{} {
+	object := c.typeOf(expr.Object)
+	value := c.typeOf(expr.Value)
+	if expr.Name.TokenType == PRIVATE_IDENTIFIER {
+		return value
+	}
+
+	switch {
+	case object.Nullable:
+		c.error(expr.Name, fmt.Sprintf("Can't set property '%s' on a value of type '%s' that may be nil.", expr.Name.Lexeme, object))
+	case object.Kind == TYPE_INSTANCE:
+		if assigned, ok := object.Class.assignedType(expr.Name.Lexeme); ok {
+			c.expect(expr.Name, value, assigned, "")
+		}
+	case object.Kind == TYPE_CLASS:
+		if assigned, ok := object.Class.findStatic(expr.Name.Lexeme); ok {
+			c.expect(expr.Name, value, assigned, "")
+		}
+	case object.Kind != TYPE_ANY:
+		c.error(expr.Name, "Only instances have fields.")
+	}
+	return value
+}
+
+func (c *TypeChecker) visitThisExpr(expr *ThisExpr) interface{} {
+	return c.currentThis
+}
+
+func (c *TypeChecker) visitSuperExpr(expr *SuperExpr) interface{} {
+	if c.currentClass == nil || c.currentClass.Superclass == nil {
+		return anyType
+	}
+	if method := c.currentClass.Superclass.findMethod(expr.Method.Lexeme); method != nil {
+		return signatureType(method)
+	}
+	return anyType
+}
+
+func (c *TypeChecker) visitInterpolationExpr(expr *InterpolationExpr) interface{} {
+	for _, part := range expr.Parts {
+		c.typeOf(part)
+	}
+	return stringType
+}
+
+func (c *TypeChecker) visitCompoundAssignmentExpr(expr *CompoundAssignmentExpr) interface{} {
+	var current, updated *Type
+
+	switch target := expr.Target.(type) {
+	case *VariableExpr:
+		current = c.typeOf(target)
+		updated = c.binary(expr.Operator, current, c.typeOf(expr.Value))
+		if binding := c.lookup(target.Name.Lexeme); binding != nil {
+			c.assign(target.Name, binding, updated)
+		}
+	case *GetExpression:
+		object := c.typeOf(target.Object)
+		current = c.get(target, object)
+		updated = c.binary(expr.Operator, current, c.typeOf(expr.Value))
+		if object.Kind == TYPE_INSTANCE && !object.Nullable {
+			if assigned, ok := object.Class.assignedType(target.Name.Lexeme); ok {
+				c.expect(target.Name, updated, assigned, "")
+			}
+		}
+	default:
+		current = c.typeOf(target)
+		updated = c.binary(expr.Operator, current, c.typeOf(expr.Value))
+	}
+
+	if expr.Postfix {
+		return current
+	}
+	return updated
+}
+
+func (c *TypeChecker) visitIndexExpr(expr *IndexExpr) interface{} {
+	object := c.typeOf(expr.Object)
+	c.typeOf(expr.Index)
+	if object.Nullable {
+		c.error(expr.Bracket, fmt.Sprintf("Can't index a value of type '%s' that may be nil.", object))
+		return anyType
+	}
+
+	switch object.Kind {
+	case TYPE_STRING:
+		return stringType
+	case TYPE_INSTANCE:
+		if method := object.Class.findMethod("__index__"); method != nil {
+			return method.Return
+		}
+		if object.Class.isOpen() {
+			return anyType
+		}
+	case TYPE_ANY, TYPE_LIST, TYPE_MAP:
+		return anyType
+	}
+	c.error(expr.Bracket, "Only lists, maps, strings and instances with __index__ can be indexed.")
+	return anyType
+}
+
+func (c *TypeChecker) visitIndexSetExpr(expr *IndexSetExpr) interface{} {
+	object := c.typeOf(expr.Object)
+	c.typeOf(expr.Index)
+	value := c.typeOf(expr.Value)
+	if object.Nullable {
+		c.error(expr.Bracket, fmt.Sprintf("Can't index a value of type '%s' that may be nil.", object))
+		return value
+	}
+
+	switch object.Kind {
+	case TYPE_ANY, TYPE_LIST, TYPE_MAP:
+		return value
+	case TYPE_INSTANCE:
+		if method := object.Class.findMethod("__setindex__"); method != nil || object.Class.isOpen() {
+			return value
+		}
+	}
+	c.error(expr.Bracket, "Only lists, maps and instances with __setindex__ support index assignment.")
+	return value
+}
+
+func (c *TypeChecker) visitListExpr(expr *ListExpr) interface{} {
+	for _, element := range expr.Elements {
+		c.typeOf(element)
+	}
+	return listType
+}
+
+func (c *TypeChecker) visitMapExpr(expr *MapExpr) interface{} {
+	for index := range expr.Keys {
+		c.typeOf(expr.Keys[index])
+		c.typeOf(expr.Values[index])
+	}
+	return mapType
+}
+
+func (c *TypeChecker) visitSpawnExpr(expr *SpawnExpr) interface{} {
+	c.typeOf(expr.Call)
+	return anyType
+}
+
+func (c *TypeChecker) visitAwaitExpr(expr *AwaitExpr) interface{} {
+	c.typeOf(expr.Value)
+	return anyType
+}
+
+func (c *TypeChecker) visitMatchExpr(expr *MatchExpr) interface{} {
+	c.typeOf(expr.Subject)
+	var result *Type
+	for _, arm := range expr.Arms {
+		c.beginScope()
+		c.checkPattern(arm)
+		if value := c.typeOf(arm.Value); result == nil {
+			result = value
+		} else {
+			result = join(result, value)
+		}
+		c.endScope()
+	}
+	if result == nil {
+		return anyType
+	}
+	return result
+}
@@ -0,0 +1,290 @@
+package main
+
+// TypeKind is the category of a static type known to the type checker
+type TypeKind int
+
+const (
+	TYPE_ANY TypeKind = iota
+	TYPE_NIL
+	TYPE_NUMBER
+	TYPE_STRING
+	TYPE_BOOL
+	TYPE_LIST
+	TYPE_MAP
+	TYPE_FUNCTION
+	// TYPE_INSTANCE is an instance of Class, TYPE_CLASS is Class itself
+	TYPE_INSTANCE
+	TYPE_CLASS
+)
+
+// Type is a static type. `any` is compatible with everything, which is what makes the
+// annotations gradual: unannotated code is never reported.
+type Type struct {
+	Kind TypeKind
+	// Nullable types also admit nil, as written `Type?`
+	Nullable bool
+	Class    *ClassShape
+	// Signature describes a TYPE_FUNCTION, nil when the function isn't known
+	Signature *Signature
+}
+
+// Signature is what the type checker knows about a function's parameters and result
+type Signature struct {
+	Name   string
+	Params []*Type
+	// Names are the parameter names, for named arguments
+	Names []string
+	// Required counts the parameters without a default value
+	Required int
+	// Variadic is set when a `...rest` parameter takes any extra arguments
+	Variadic bool
+	Return   *Type
+	// Wrapped is set for async functions and generators, whose calls produce a promise or
+	// a generator rather than Return itself
+	Wrapped bool
+}
+
+// ClassShape describes the members of a class. Classes that declare typed fields are
+// expected to declare all of their fields, other classes may have any property.
+type ClassShape struct {
+	Name       string
+	Superclass *ClassShape
+	Fields     map[string]*Type
+	Getters    map[string]*Type
+	Methods    map[string]*Signature
+	Setters    map[string]*Type
+	Statics    map[string]*Type
+	// Open is set when traits add members the checker doesn't track, so any property may exist
+	Open bool
+}
+
+var (
+	anyType      = &Type{Kind: TYPE_ANY}
+	nilType      = &Type{Kind: TYPE_NIL}
+	numberType   = &Type{Kind: TYPE_NUMBER}
+	stringType   = &Type{Kind: TYPE_STRING}
+	boolType     = &Type{Kind: TYPE_BOOL}
+	listType     = &Type{Kind: TYPE_LIST}
+	mapType      = &Type{Kind: TYPE_MAP}
+	functionType = &Type{Kind: TYPE_FUNCTION}
+)
+
+// builtinTypes are the type names that don't name a class
+var builtinTypes = map[string]*Type{
+	"any":      anyType,
+	"nil":      nilType,
+	"number":   numberType,
+	"string":   stringType,
+	"bool":     boolType,
+	"list":     listType,
+	"map":      mapType,
+	"function": functionType,
+}
+
+func NewClassShape(name string) *ClassShape {
+	return &ClassShape{
+		Name:    name,
+		Fields:  make(map[string]*Type),
+		Getters: make(map[string]*Type),
+		Methods: make(map[string]*Signature),
+		Setters: make(map[string]*Type),
+		Statics: make(map[string]*Type),
+	}
+}
+
+func instanceType(class *ClassShape) *Type {
+	return &Type{Kind: TYPE_INSTANCE, Class: class}
+}
+
+func signatureType(signature *Signature) *Type {
+	return &Type{Kind: TYPE_FUNCTION, Signature: signature}
+}
+
+// orNil returns the nullable version of t
+func (t *Type) orNil() *Type {
+	if t.Nullable || t.Kind == TYPE_ANY || t.Kind == TYPE_NIL {
+		return t
+	}
+	nullable := *t
+	nullable.Nullable = true
+	return &nullable
+}
+
+// nonNil returns t without nil, as known once a value was compared against nil
+func (t *Type) nonNil() *Type {
+	if !t.Nullable {
+		return t
+	}
+	present := *t
+	present.Nullable = false
+	return &present
+}
+
+// mayBeNil reports whether a value of type t can be nil, leaving `any` out
+func (t *Type) mayBeNil() bool {
+	return t.Nullable || t.Kind == TYPE_NIL
+}
+
+// assignableTo reports whether a value of type t can be used where target is expected.
+// Instances of a subclass can be used for their superclass.
+func (t *Type) assignableTo(target *Type) bool {
+	if t.Kind == TYPE_ANY || target.Kind == TYPE_ANY {
+		return true
+	}
+	if t.Kind == TYPE_NIL {
+		return target.Nullable || target.Kind == TYPE_NIL
+	}
+	if t.Nullable && !target.Nullable {
+		return false
+	}
+	if t.Kind != target.Kind {
+		return false
+	}
+
+	switch t.Kind {
+	case TYPE_INSTANCE, TYPE_CLASS:
+		return t.Class.isSubclassOf(target.Class)
+	case TYPE_FUNCTION:
+		return t.Signature == nil || target.Signature == nil || t.Signature.assignableTo(target.Signature)
+	}
+	return true
+}
+
+// join is the type of a value that is either a or b
+func join(a *Type, b *Type) *Type {
+	switch {
+	case a.Kind == TYPE_NIL:
+		return b.orNil()
+	case b.Kind == TYPE_NIL:
+		return a.orNil()
+	case a.assignableTo(b):
+		return b
+	case b.assignableTo(a):
+		return a
+	}
+	return anyType
+}
+
+func (t *Type) String() string {
+	var name string
+	switch t.Kind {
+	case TYPE_ANY:
+		return "any"
+	case TYPE_NIL:
+		return "nil"
+	case TYPE_NUMBER:
+		name = "number"
+	case TYPE_STRING:
+		name = "string"
+	case TYPE_BOOL:
+		name = "bool"
+	case TYPE_LIST:
+		name = "list"
+	case TYPE_MAP:
+		name = "map"
+	case TYPE_FUNCTION:
+		name = "function"
+	case TYPE_INSTANCE:
+		name = t.Class.Name
+	case TYPE_CLASS:
+		name = "class " + t.Class.Name
+	}
+	if t.Nullable {
+		return name + "?"
+	}
+	return name
+}
+
+// assignableTo reports whether a function with signature s can stand in for one with
+// target: it takes at least the parameters target is called with, and returns what
+// target returns
+func (s *Signature) assignableTo(target *Signature) bool {
+	if s.Required > target.Required || (!s.Variadic && len(s.Params) < len(target.Params)) {
+		return false
+	}
+	for index, param := range target.Params {
+		if index < len(s.Params) && !param.assignableTo(s.Params[index]) {
+			return false
+		}
+	}
+	return s.Return.assignableTo(target.Return)
+}
+
+func (c *ClassShape) isSubclassOf(other *ClassShape) bool {
+	for class := c; class != nil; class = class.Superclass {
+		if class == other {
+			return true
+		}
+	}
+	return false
+}
+
+// isOpen reports whether traits may add members to instances of c
+func (c *ClassShape) isOpen() bool {
+	for class := c; class != nil; class = class.Superclass {
+		if class.Open {
+			return true
+		}
+	}
+	return false
+}
+
+// isClosed reports whether reading a property that isn't declared is an error
+func (c *ClassShape) isClosed() bool {
+	if c.isOpen() {
+		return false
+	}
+	for class := c; class != nil; class = class.Superclass {
+		if len(class.Fields) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// property looks up the type of reading name on an instance, walking up the superclasses
+func (c *ClassShape) property(name string) (*Type, bool) {
+	for class := c; class != nil; class = class.Superclass {
+		if field, ok := class.Fields[name]; ok {
+			return field, true
+		}
+		if getter, ok := class.Getters[name]; ok {
+			return getter, true
+		}
+		if method, ok := class.Methods[name]; ok {
+			return signatureType(method), true
+		}
+	}
+	return nil, false
+}
+
+// assignedType looks up what may be assigned to the property name of an instance
+func (c *ClassShape) assignedType(name string) (*Type, bool) {
+	for class := c; class != nil; class = class.Superclass {
+		if setter, ok := class.Setters[name]; ok {
+			return setter, true
+		}
+		if field, ok := class.Fields[name]; ok {
+			return field, true
+		}
+	}
+	return nil, false
+}
+
+func (c *ClassShape) findMethod(name string) *Signature {
+	for class := c; class != nil; class = class.Superclass {
+		if method, ok := class.Methods[name]; ok {
+			return method
+		}
+	}
+	return nil
+}
+
+func (c *ClassShape) findStatic(name string) (*Type, bool) {
+	for class := c; class != nil; class = class.Superclass {
+		if static, ok := class.Statics[name]; ok {
+			return static, true
+		}
+	}
+	return nil, false
+}